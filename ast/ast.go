@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"strings"
 
 	"github.com/labasubagia/interpreter/token"
@@ -10,6 +11,7 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Span() token.Span
 }
 
 type Statement interface {
@@ -33,6 +35,13 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Span() token.Span {
+	if len(p.Statements) > 0 {
+		return spanOf(p.Statements[0].Span(), p.Statements[len(p.Statements)-1].Span())
+	}
+	return token.Span{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -55,6 +64,13 @@ func (l *LetStatement) TokenLiteral() string {
 	return l.Token.Literal
 }
 
+func (l *LetStatement) Span() token.Span {
+	if l.Value != nil {
+		return spanOf(l.Token.Span, l.Value.Span())
+	}
+	return spanOf(l.Token.Span, l.Name.Span())
+}
+
 func (l *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(l.TokenLiteral() + " ")
@@ -82,6 +98,10 @@ func (ae *AssignExpression) TokenLiteral() string {
 	return ae.Token.Literal
 }
 
+func (ae *AssignExpression) Span() token.Span {
+	return spanOf(ae.Left.Span(), ae.Value.Span())
+}
+
 func (ae *AssignExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString(ae.Left.String())
@@ -104,6 +124,13 @@ func (r *ReturnStatement) TokenLiteral() string {
 	return r.Token.Literal
 }
 
+func (r *ReturnStatement) Span() token.Span {
+	if r.ReturnValue != nil {
+		return spanOf(r.Token.Span, r.ReturnValue.Span())
+	}
+	return r.Token.Span
+}
+
 func (r *ReturnStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(r.TokenLiteral() + " ")
@@ -127,6 +154,13 @@ func (e *ExpressionStatement) TokenLiteral() string {
 	return e.Token.Literal
 }
 
+func (e *ExpressionStatement) Span() token.Span {
+	if e.Expression != nil {
+		return e.Expression.Span()
+	}
+	return e.Token.Span
+}
+
 func (e *ExpressionStatement) String() string {
 	if e.Expression != nil {
 		return e.Expression.String()
@@ -147,6 +181,10 @@ func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *Identifier) Span() token.Span {
+	return i.Token.Span
+}
+
 func (i *Identifier) String() string {
 	return i.Value
 }
@@ -163,13 +201,39 @@ func (n *Null) TokenLiteral() string {
 	return n.Token.Literal
 }
 
+func (n *Null) Span() token.Span {
+	return n.Token.Span
+}
+
 func (n *Null) String() string {
 	return "null"
 }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {
+
+}
+
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+
+func (fl *FloatLiteral) Span() token.Span {
+	return fl.Token.Span
+}
+
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int // set instead of Value when the literal does not fit int64
 }
 
 func (il *IntegerLiteral) expressionNode() {
@@ -180,6 +244,10 @@ func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
 
+func (il *IntegerLiteral) Span() token.Span {
+	return il.Token.Span
+}
+
 func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
@@ -196,6 +264,10 @@ func (pe *PrefixExpression) expressionNode() {
 func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
+
+func (pe *PrefixExpression) Span() token.Span {
+	return spanOf(pe.Token.Span, pe.Right.Span())
+}
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -218,6 +290,10 @@ func (ie *InfixExpression) expressionNode() {
 func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+
+func (ie *InfixExpression) Span() token.Span {
+	return spanOf(ie.Left.Span(), ie.Right.Span())
+}
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -228,6 +304,35 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+// LogicalExpression is `left && right` or `left || right`,
+// right is only evaluated when left does not decide the result
+type LogicalExpression struct {
+	Token    token.Token // The operator token, && or ||
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+func (le *LogicalExpression) expressionNode() {
+
+}
+func (le *LogicalExpression) TokenLiteral() string {
+	return le.Token.Literal
+}
+
+func (le *LogicalExpression) Span() token.Span {
+	return spanOf(le.Left.Span(), le.Right.Span())
+}
+func (le *LogicalExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(le.Left.String())
+	out.WriteString(" " + le.Operator + " ")
+	out.WriteString(le.Right.String())
+	out.WriteString(")")
+	return out.String()
+}
+
 type Boolean struct {
 	Token token.Token
 	Value bool
@@ -239,6 +344,10 @@ func (b *Boolean) expressionNode() {
 func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
+
+func (b *Boolean) Span() token.Span {
+	return b.Token.Span
+}
 func (b *Boolean) String() string {
 	return b.Token.Literal
 }
@@ -256,6 +365,13 @@ func (ie *IfExpression) expressionNode() {
 func (ie *IfExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+
+func (ie *IfExpression) Span() token.Span {
+	if ie.Alternative != nil {
+		return spanOf(ie.Token.Span, ie.Alternative.Span())
+	}
+	return spanOf(ie.Token.Span, ie.Consequence.Span())
+}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -278,8 +394,9 @@ func (ie *IfExpression) String() string {
 }
 
 type BlockStatement struct {
-	Token      token.Token
+	Token      token.Token // the '{' token
 	Statements []Statement
+	Rbrace     token.Token
 }
 
 func (bs *BlockStatement) statementNode() {
@@ -288,6 +405,10 @@ func (bs *BlockStatement) statementNode() {
 func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
+
+func (bs *BlockStatement) Span() token.Span {
+	return spanOf(bs.Token.Span, bs.Rbrace.Span)
+}
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 	for _, s := range bs.Statements {
@@ -299,6 +420,8 @@ func (bs *BlockStatement) String() string {
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
+	Defaults   []Expression // default value by parameter, nil when the parameter has none
+	Rest       *Identifier  // collects the extra arguments in an array, nil when there is none
 	Body       *BlockStatement
 }
 
@@ -308,27 +431,64 @@ func (fl *FunctionLiteral) expressionNode() {
 func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
+
+func (fl *FunctionLiteral) Span() token.Span {
+	return spanOf(fl.Token.Span, fl.Body.Span())
+}
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ParametersString(fl.Parameters, fl.Defaults, fl.Rest))
 	out.WriteString(") ")
 	out.WriteString(fl.Body.String())
 
 	return out.String()
 }
 
+// ParametersString returns the parameters of a function, e.g. "a, b = 10, ...rest"
+func ParametersString(parameters []*Identifier, defaults []Expression, rest *Identifier) string {
+	params := []string{}
+	for i, p := range parameters {
+		if i < len(defaults) && defaults[i] != nil {
+			params = append(params, p.String()+" = "+defaults[i].String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if rest != nil {
+		params = append(params, "..."+rest.String())
+	}
+	return strings.Join(params, ", ")
+}
+
+// SpreadExpression is `...value` in the arguments of a call or the elements of an array literal,
+// the elements of the array value take its place
+type SpreadExpression struct {
+	Token token.Token // the '...' token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode() {
+
+}
+func (se *SpreadExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+
+func (se *SpreadExpression) Span() token.Span {
+	return spanOf(se.Token.Span, se.Value.Span())
+}
+func (se *SpreadExpression) String() string {
+	return "..." + se.Value.String()
+}
+
 type CallExpression struct {
-	Token     token.Token
+	Token     token.Token // the '(' token
 	Function  Expression
 	Arguments []Expression
+	Rparen    token.Token
 }
 
 func (ce *CallExpression) expressionNode() {
@@ -339,6 +499,10 @@ func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
 
+func (ce *CallExpression) Span() token.Span {
+	return spanOf(ce.Function.Span(), ce.Rparen.Span)
+}
+
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -368,13 +532,53 @@ func (sl *StringLiteral) TokenLiteral() string {
 	return sl.Token.Literal
 }
 
+func (sl *StringLiteral) Span() token.Span {
+	return sl.Token.Span
+}
+
 func (sl *StringLiteral) String() string {
 	return sl.Token.Literal
 }
 
+// InterpolatedString is "a ${x} b", Parts alternate between
+// the string literals and the embedded expressions, starting and ending with a literal
+type InterpolatedString struct {
+	Token token.Token // the STRING_START token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode() {
+
+}
+
+func (is *InterpolatedString) TokenLiteral() string {
+	return is.Token.Literal
+}
+
+func (is *InterpolatedString) Span() token.Span {
+	return spanOf(is.Token.Span, is.Parts[len(is.Parts)-1].Span())
+}
+
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("\"")
+	for i, part := range is.Parts {
+		if i%2 == 0 {
+			out.WriteString(part.String())
+		} else {
+			out.WriteString("${" + part.String() + "}")
+		}
+	}
+	out.WriteString("\"")
+
+	return out.String()
+}
+
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
+	Rbracket token.Token
 }
 
 func (al *ArrayLiteral) expressionNode() {
@@ -385,6 +589,10 @@ func (al *ArrayLiteral) TokenLiteral() string {
 	return al.Token.Literal
 }
 
+func (al *ArrayLiteral) Span() token.Span {
+	return spanOf(al.Token.Span, al.Rbracket.Span)
+}
+
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -401,9 +609,10 @@ func (al *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token
-	Left  Expression
-	Index Expression
+	Token    token.Token // the '[' token
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token
 }
 
 func (ie *IndexExpression) expressionNode() {
@@ -412,6 +621,10 @@ func (ie *IndexExpression) expressionNode() {
 func (ie *IndexExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+
+func (ie *IndexExpression) Span() token.Span {
+	return spanOf(ie.Left.Span(), ie.Rbracket.Span)
+}
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -422,9 +635,56 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression is left[start:end:step], the omitted parts are nil
+type SliceExpression struct {
+	Token    token.Token // the '[' token
+	Left     Expression
+	Start    Expression
+	End      Expression
+	Step     Expression
+	Rbracket token.Token
+}
+
+func (se *SliceExpression) expressionNode() {
+
+}
+func (se *SliceExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+
+func (se *SliceExpression) Span() token.Span {
+	return spanOf(se.Left.Span(), se.Rbracket.Span)
+}
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	if se.Step != nil {
+		out.WriteString(":")
+		out.WriteString(se.Step.String())
+	}
+	out.WriteString("])")
+	return out.String()
+}
+
 type HashLiteral struct {
-	Token token.Token
-	Pairs map[Expression]Expression
+	Token  token.Token // the '{' token
+	Pairs  []HashPair  // in the order of the source
+	Rbrace token.Token
+}
+
+// HashPair is a `key: value` of a hash literal
+type HashPair struct {
+	Key   Expression
+	Value Expression
 }
 
 func (hl *HashLiteral) expressionNode() {
@@ -433,12 +693,16 @@ func (hl *HashLiteral) expressionNode() {
 func (hl *HashLiteral) TokenLiteral() string {
 	return hl.Token.Literal
 }
+
+func (hl *HashLiteral) Span() token.Span {
+	return spanOf(hl.Token.Span, hl.Rbrace.Span)
+}
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, val := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+val.String())
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
 	}
 
 	out.WriteString("{")
@@ -460,6 +724,10 @@ func (ie *WhileStatement) statementNode() {
 func (ie *WhileStatement) TokenLiteral() string {
 	return ie.Token.Literal
 }
+
+func (ie *WhileStatement) Span() token.Span {
+	return spanOf(ie.Token.Span, ie.Body.Span())
+}
 func (ie *WhileStatement) String() string {
 	var out bytes.Buffer
 
@@ -474,6 +742,87 @@ func (ie *WhileStatement) String() string {
 	return out.String()
 }
 
+// ForStatement is `for (init; condition; update) { body }`, every part of the header is optional
+type ForStatement struct {
+	Token     token.Token // the `for` token
+	Init      Statement
+	Condition Expression // loops until break when nil
+	Update    Expression
+	Body      *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {
+
+}
+func (fs *ForStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+
+func (fs *ForStatement) Span() token.Span {
+	return spanOf(fs.Token.Span, fs.Body.Span())
+}
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for")
+	out.WriteString("(")
+	if fs.Init != nil {
+		out.WriteString(strings.TrimSuffix(fs.Init.String(), ";"))
+	}
+	out.WriteString("; ")
+	if fs.Condition != nil {
+		out.WriteString(fs.Condition.String())
+	}
+	out.WriteString("; ")
+	if fs.Update != nil {
+		out.WriteString(strings.TrimSuffix(fs.Update.String(), ";"))
+	}
+	out.WriteString(")")
+	out.WriteString("{")
+	out.WriteString(fs.Body.String())
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// ForInStatement is `for (value in iterable) { body }` or `for (key, value in iterable) { body }`
+type ForInStatement struct {
+	Token    token.Token // the `for` token
+	Key      *Identifier // nil with a single variable
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForInStatement) statementNode() {
+
+}
+func (fs *ForInStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+
+func (fs *ForInStatement) Span() token.Span {
+	return spanOf(fs.Token.Span, fs.Body.Span())
+}
+func (fs *ForInStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for")
+	out.WriteString("(")
+	if fs.Key != nil {
+		out.WriteString(fs.Key.String() + ", ")
+	}
+	out.WriteString(fs.Value.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(")")
+	out.WriteString("{")
+	out.WriteString(fs.Body.String())
+	out.WriteString("}")
+
+	return out.String()
+}
+
 type BreakStatement struct {
 	Token token.Token //  the `break` token
 }
@@ -486,6 +835,10 @@ func (bs *BreakStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
 
+func (bs *BreakStatement) Span() token.Span {
+	return bs.Token.Span
+}
+
 func (bs *BreakStatement) String() string {
 	return bs.Token.Literal
 }
@@ -502,6 +855,139 @@ func (cs *ContinueStatement) TokenLiteral() string {
 	return cs.Token.Literal
 }
 
+func (cs *ContinueStatement) Span() token.Span {
+	return cs.Token.Span
+}
+
 func (cs *ContinueStatement) String() string {
 	return cs.Token.Literal
 }
+
+// TryStatement is `try { } catch (e) { } finally { }`, with the catch or the finally optional
+type TryStatement struct {
+	Token      token.Token // the `try` token
+	Block      *BlockStatement
+	CatchParam *Identifier     // nil when the catch binds no variable
+	Catch      *BlockStatement // nil without a catch
+	Finally    *BlockStatement // nil without a finally
+}
+
+func (ts *TryStatement) statementNode() {
+
+}
+
+func (ts *TryStatement) TokenLiteral() string {
+	return ts.Token.Literal
+}
+
+func (ts *TryStatement) Span() token.Span {
+	if ts.Finally != nil {
+		return spanOf(ts.Token.Span, ts.Finally.Span())
+	}
+	return spanOf(ts.Token.Span, ts.Catch.Span())
+}
+
+func (ts *TryStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try")
+	out.WriteString("{")
+	out.WriteString(ts.Block.String())
+	out.WriteString("}")
+	if ts.Catch != nil {
+		out.WriteString("catch")
+		if ts.CatchParam != nil {
+			out.WriteString("(" + ts.CatchParam.String() + ")")
+		}
+		out.WriteString("{")
+		out.WriteString(ts.Catch.String())
+		out.WriteString("}")
+	}
+	if ts.Finally != nil {
+		out.WriteString("finally")
+		out.WriteString("{")
+		out.WriteString(ts.Finally.String())
+		out.WriteString("}")
+	}
+
+	return out.String()
+}
+
+type ThrowStatement struct {
+	Token token.Token // the `throw` token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode() {
+
+}
+
+func (ts *ThrowStatement) TokenLiteral() string {
+	return ts.Token.Literal
+}
+
+func (ts *ThrowStatement) Span() token.Span {
+	if ts.Value != nil {
+		return spanOf(ts.Token.Span, ts.Value.Span())
+	}
+	return ts.Token.Span
+}
+
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(ts.TokenLiteral() + " ")
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
+// ImportExpression is `import "path"`, its value is the namespace of the module
+type ImportExpression struct {
+	Token token.Token // the `import` token
+	Path  *StringLiteral
+}
+
+func (ie *ImportExpression) expressionNode() {
+
+}
+
+func (ie *ImportExpression) TokenLiteral() string {
+	return ie.Token.Literal
+}
+
+func (ie *ImportExpression) Span() token.Span {
+	return spanOf(ie.Token.Span, ie.Path.Span())
+}
+
+func (ie *ImportExpression) String() string {
+	return ie.TokenLiteral() + " " + ie.Path.String()
+}
+
+// ExportStatement is `export let name = value;` at the top level of a module
+type ExportStatement struct {
+	Token     token.Token // the `export` token
+	Statement *LetStatement
+}
+
+func (es *ExportStatement) statementNode() {
+
+}
+
+func (es *ExportStatement) TokenLiteral() string {
+	return es.Token.Literal
+}
+
+func (es *ExportStatement) Span() token.Span {
+	return spanOf(es.Token.Span, es.Statement.Span())
+}
+
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
+// spanOf joins the start of the first span with the end of the last
+func spanOf(first, last token.Span) token.Span {
+	return token.Span{Start: first.Start, End: last.End}
+}
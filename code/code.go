@@ -0,0 +1,284 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s", i, ins.fmtInstruction(def, operands))
+
+		// closure captures follow the instruction, two bytes per captured variable
+		if Opcode(ins[i]) == OpClosure {
+			for j := 0; j < operands[1]; j++ {
+				offset := i + 1 + read + j*2
+				fmt.Fprintf(&out, " [%d %d]", ins[offset], ins[offset+1])
+			}
+			read += operands[1] * 2
+		}
+		out.WriteString("\n")
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpGreaterThanEqual
+	OpLessThan
+	OpLessThanEqual
+
+	OpMinus
+	OpBang
+
+	OpJump
+	OpJumpNotTruthy
+	OpJumpNotTruthyOrPop // for &&, jumps keeping the value on the stack, otherwise pops it
+	OpJumpTruthyOrPop    // for ||, the same for a truthy value
+
+	OpIter     // pops a collection, pushes an iterator over it
+	OpIterNext // pushes the next element (and key) of the iterator on top, jumps when there is none
+
+	OpGetGlobal
+	OpSetGlobal    // define a global, pops the value
+	OpAssignGlobal // assign an existing global, keeps the value on the stack
+	OpGetLocal
+	OpSetLocal
+	OpAssignLocal
+	OpGetFree
+	OpAssignFree
+	OpCompoundAssign // pops current value and operand, pushes the result
+
+	OpArray
+	OpHash
+	OpIndex
+	OpSetIndex
+
+	OpCall
+	OpReturnValue
+	OpReturn
+	OpClosure     // followed by two bytes (isLocal, index) for every captured variable
+	OpSkipDefault // jumps over the default value of a parameter when the argument was given
+
+	OpConcat     // pops arrays, pushes their concatenation
+	OpCallSpread // calls with the elements of the array on top as arguments
+
+	OpTry    // starts a try block, an error jumps to the catch block with the error on the stack
+	OpEndTry // ends the innermost try block
+	OpThrow  // pops a value and raises it as an error
+	OpCatch  // replaces the error on top with the value seen by the catch block
+
+	OpImport // pushes the namespace of the module named by a constant
+
+	OpInterpolate // pops the parts of an interpolated string, pushes the joined string
+
+	OpSlice    // pops left, start, end and step, pushes left[start:end:step]
+	OpSetSlice // pops left, start, end, step and the value, assigns left[start:end:step]
+)
+
+// AssignOperators are referenced by index from OpCompoundAssign, OpSetIndex and OpSetSlice
+var AssignOperators = []string{"=", "+=", "-=", "*=", "/=", "%="}
+
+func AssignOperatorIndex(operator string) int {
+	for i, op := range AssignOperators {
+		if op == operator {
+			return i
+		}
+	}
+	return -1
+}
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd: {"OpAdd", []int{}},
+	OpSub: {"OpSub", []int{}},
+	OpMul: {"OpMul", []int{}},
+	OpDiv: {"OpDiv", []int{}},
+	OpMod: {"OpMod", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpEqual:            {"OpEqual", []int{}},
+	OpNotEqual:         {"OpNotEqual", []int{}},
+	OpGreaterThan:      {"OpGreaterThan", []int{}},
+	OpGreaterThanEqual: {"OpGreaterThanEqual", []int{}},
+	OpLessThan:         {"OpLessThan", []int{}},
+	OpLessThanEqual:    {"OpLessThanEqual", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+
+	OpJumpNotTruthyOrPop: {"OpJumpNotTruthyOrPop", []int{2}},
+	OpJumpTruthyOrPop:    {"OpJumpTruthyOrPop", []int{2}},
+
+	OpIter:     {"OpIter", []int{}},
+	OpIterNext: {"OpIterNext", []int{2, 1}}, // jump target, number of loop variables
+
+	OpGetGlobal:      {"OpGetGlobal", []int{2}},
+	OpSetGlobal:      {"OpSetGlobal", []int{2}},
+	OpAssignGlobal:   {"OpAssignGlobal", []int{2}},
+	OpGetLocal:       {"OpGetLocal", []int{1}},
+	OpSetLocal:       {"OpSetLocal", []int{1}},
+	OpAssignLocal:    {"OpAssignLocal", []int{1}},
+	OpGetFree:        {"OpGetFree", []int{1}},
+	OpAssignFree:     {"OpAssignFree", []int{1}},
+	OpCompoundAssign: {"OpCompoundAssign", []int{1}},
+
+	OpArray:    {"OpArray", []int{2}},
+	OpHash:     {"OpHash", []int{2}},
+	OpIndex:    {"OpIndex", []int{}},
+	OpSetIndex: {"OpSetIndex", []int{1, 2}},
+
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+	OpClosure:     {"OpClosure", []int{2, 1}},
+	OpSkipDefault: {"OpSkipDefault", []int{2, 1}}, // jump target, local index of the parameter
+
+	OpConcat:     {"OpConcat", []int{2}},
+	OpCallSpread: {"OpCallSpread", []int{}},
+
+	OpTry:    {"OpTry", []int{2}},
+	OpEndTry: {"OpEndTry", []int{}},
+	OpThrow:  {"OpThrow", []int{}},
+	OpCatch:  {"OpCatch", []int{}},
+
+	OpImport: {"OpImport", []int{2}},
+
+	OpInterpolate: {"OpInterpolate", []int{2}},
+
+	OpSlice:    {"OpSlice", []int{}},
+	OpSetSlice: {"OpSetSlice", []int{1}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// Fits reports whether operand fits in width bytes, Make truncates it otherwise
+func Fits(operand, width int) bool {
+	return operand >= 0 && operand < 1<<(8*width)
+}
+
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
@@ -0,0 +1,1059 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/labasubagia/interpreter/ast"
+	"github.com/labasubagia/interpreter/code"
+	"github.com/labasubagia/interpreter/object"
+	"github.com/labasubagia/interpreter/token"
+)
+
+type CompileError struct {
+	Message  string
+	Position token.Position
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Position, e.Message)
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type loop struct {
+	breaks    []int // offsets of the jumps to patch with the loop end
+	continues []int // offsets of the jumps to patch with the start of the next iteration
+}
+
+// try is a try block being compiled, its finally block is compiled again on
+// every way out of it, e.g. a return
+type try struct {
+	finally *ast.BlockStatement // nil without a finally
+	loops   int                 // loops around the try block
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	positions           []object.SourcePosition
+	loops               []*loop
+	tries               []*try
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+	scopes      []CompilationScope
+	scopeIndex  int
+	position    token.Position // source position of the node being compiled
+	exports     map[string]int // global index by exported name
+	tooLarge    error          // the first operand that does not fit, see checkOperands
+}
+
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), []object.Object{})
+}
+
+// NewWithState keeps globals and constants between compilations, e.g. in the REPL
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	return &Compiler{
+		constants:   constants,
+		symbolTable: s,
+		scopes:      []CompilationScope{{}},
+		exports:     map[string]int{},
+	}
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	outer := c.position
+	c.position = node.Span().Start
+	defer func() { c.position = outer }()
+
+	switch node := node.(type) {
+	case *ast.Program:
+		c.tooLarge = nil
+		if err := c.compileProgram(node); err != nil {
+			return err
+		}
+		return c.tooLarge
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.LetStatement:
+		return c.compileLetStatement(node)
+
+	case *ast.ExportStatement:
+		if err := c.compileLetStatement(node.Statement); err != nil {
+			return err
+		}
+		symbol, _ := c.symbolTable.Resolve(node.Statement.Name.Value)
+		if symbol.Scope != GlobalScope {
+			return c.errorf("export is only allowed at the top level of a module")
+		}
+		c.exports[symbol.Name] = symbol.Index
+
+	case *ast.ImportExpression:
+		name := &object.String{Value: node.Path.Value}
+		c.emit(code.OpImport, c.addConstant(name))
+
+	case *ast.ReturnStatement:
+		if c.scopeIndex == 0 && len(c.currentScope().loops) > 0 {
+			return c.errorf("return statement unsupported if while-loop not inside a function")
+		}
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		if err := c.leaveTries(0); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+
+	case *ast.ForInStatement:
+		return c.compileForInStatement(node)
+
+	case *ast.TryStatement:
+		return c.compileTryStatement(node)
+
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+
+	case *ast.BreakStatement:
+		l, err := c.currentLoop(node)
+		if err != nil {
+			return err
+		}
+		if err := c.leaveTries(c.loopTries()); err != nil {
+			return err
+		}
+		l.breaks = append(l.breaks, c.emit(code.OpJump, 9999))
+
+	case *ast.ContinueStatement:
+		l, err := c.currentLoop(node)
+		if err != nil {
+			return err
+		}
+		if err := c.leaveTries(c.loopTries()); err != nil {
+			return err
+		}
+		l.continues = append(l.continues, c.emit(code.OpJump, 9999))
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.IntegerLiteral:
+		var integer object.Object = &object.Integer{Value: node.Value}
+		if node.Big != nil {
+			integer = &object.BigInteger{Value: node.Big}
+		}
+		c.emit(code.OpConstant, c.addConstant(integer))
+
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			if err := c.Compile(part); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpInterpolate, len(node.Parts))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.Null:
+		c.emit(code.OpNull)
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return c.errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		return c.compileInfixExpression(node)
+
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+
+	case *ast.LogicalExpression:
+		return c.compileLogicalExpression(node)
+
+	case *ast.Identifier:
+		c.loadSymbol(c.resolve(node.Value))
+
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+
+	case *ast.ArrayLiteral:
+		if hasSpread(node.Elements) {
+			return c.compileSpreadList(node.Elements)
+		}
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.SliceExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.compileSliceBounds(node); err != nil {
+			return err
+		}
+		c.emit(code.OpSlice)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node, "")
+
+	case *ast.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		if hasSpread(node.Arguments) {
+			if err := c.compileSpreadList(node.Arguments); err != nil {
+				return err
+			}
+			c.emit(code.OpCallSpread)
+			return nil
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+
+	default:
+		return c.errorf("unsupported node %T", node)
+	}
+
+	return nil
+}
+
+func hasSpread(exps []ast.Expression) bool {
+	for _, exp := range exps {
+		if _, ok := exp.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compileSpreadList leaves an array of exps on the stack, e.g. [1, ...a] is built
+// by concatenating [1] and a
+func (c *Compiler) compileSpreadList(exps []ast.Expression) error {
+	segments, pending := 0, 0
+	for _, exp := range exps {
+		spread, ok := exp.(*ast.SpreadExpression)
+		if !ok {
+			if err := c.Compile(exp); err != nil {
+				return err
+			}
+			pending++
+			continue
+		}
+
+		if pending > 0 {
+			c.emit(code.OpArray, pending)
+			segments, pending = segments+1, 0
+		}
+		if err := c.Compile(spread.Value); err != nil {
+			return err
+		}
+		segments++
+	}
+	if pending > 0 {
+		c.emit(code.OpArray, pending)
+		segments++
+	}
+
+	c.emit(code.OpConcat, segments)
+	return nil
+}
+
+func (c *Compiler) compileProgram(program *ast.Program) error {
+	for _, s := range program.Statements {
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+	}
+
+	// the result of the program is the value of the last statement
+	if n := len(program.Statements); n > 0 {
+		switch program.Statements[n-1].(type) {
+		case *ast.ExpressionStatement:
+			c.replaceLastPopWith(code.OpReturnValue)
+			return nil
+		case *ast.WhileStatement, *ast.ForStatement, *ast.ForInStatement, *ast.TryStatement:
+			c.emit(code.OpNull)
+			c.emit(code.OpReturnValue)
+			return nil
+		}
+	}
+	c.emit(code.OpReturn)
+	return nil
+}
+
+func (c *Compiler) compileLetStatement(node *ast.LetStatement) error {
+	var symbol Symbol
+
+	// define functions first, so they can call themselves
+	if fn, ok := node.Value.(*ast.FunctionLiteral); ok {
+		symbol = c.symbolTable.Define(node.Name.Value)
+		if err := c.compileFunctionLiteral(fn, node.Name.Value); err != nil {
+			return err
+		}
+	} else {
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		symbol = c.symbolTable.Define(node.Name.Value)
+	}
+
+	c.setSymbol(symbol)
+	return nil
+}
+
+// setSymbol pops the top of the stack into the variable of symbol
+func (c *Compiler) setSymbol(symbol Symbol) {
+	if symbol.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, symbol.Index)
+	} else {
+		c.emit(code.OpSetLocal, symbol.Index)
+	}
+}
+
+func (c *Compiler) compileInfixExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+
+	switch node.Operator {
+	case "+":
+		c.emit(code.OpAdd)
+	case "-":
+		c.emit(code.OpSub)
+	case "*":
+		c.emit(code.OpMul)
+	case "/":
+		c.emit(code.OpDiv)
+	case "%":
+		c.emit(code.OpMod)
+	case "==":
+		c.emit(code.OpEqual)
+	case "!=":
+		c.emit(code.OpNotEqual)
+	case ">":
+		c.emit(code.OpGreaterThan)
+	case ">=":
+		c.emit(code.OpGreaterThanEqual)
+	case "<":
+		c.emit(code.OpLessThan)
+	case "<=":
+		c.emit(code.OpLessThanEqual)
+	default:
+		return c.errorf("unknown operator %s", node.Operator)
+	}
+	return nil
+}
+
+// compileLogicalExpression leaves the operand deciding the result on the stack,
+// the right one is skipped when the left one decides it
+func (c *Compiler) compileLogicalExpression(node *ast.LogicalExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	var jumpPos int
+	switch node.Operator {
+	case "&&":
+		jumpPos = c.emit(code.OpJumpNotTruthyOrPop, 9999)
+	case "||":
+		jumpPos = c.emit(code.OpJumpTruthyOrPop, 9999)
+	default:
+		return c.errorf("unknown operator %s", node.Operator)
+	}
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBlockExpression(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else {
+		if err := c.compileBlockExpression(node.Alternative); err != nil {
+			return err
+		}
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileBlockExpression leaves the value of the last statement on the stack
+func (c *Compiler) compileBlockExpression(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if n := len(block.Statements); n > 0 {
+		if _, ok := block.Statements[n-1].(*ast.ExpressionStatement); ok {
+			c.removeLastPop()
+			return nil
+		}
+	}
+	c.emit(code.OpNull)
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	defer func() { c.symbolTable = c.symbolTable.Outer }()
+
+	l, leave := c.enterLoop()
+	defer leave()
+
+	start := len(c.currentInstructions())
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	exitPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+
+	end := len(c.currentInstructions())
+	c.changeOperand(exitPos, end)
+	c.patchLoop(l, start, end)
+	return nil
+}
+
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	defer func() { c.symbolTable = c.symbolTable.Outer }()
+
+	if node.Init != nil {
+		if err := c.Compile(node.Init); err != nil {
+			return err
+		}
+	}
+
+	l, leave := c.enterLoop()
+	defer leave()
+
+	start := len(c.currentInstructions())
+	exitPos := -1
+	if node.Condition != nil {
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+		exitPos = c.emit(code.OpJumpNotTruthy, 9999)
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	next := len(c.currentInstructions())
+	if node.Update != nil {
+		if err := c.Compile(node.Update); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	}
+	c.emit(code.OpJump, start)
+
+	end := len(c.currentInstructions())
+	if exitPos >= 0 {
+		c.changeOperand(exitPos, end)
+	}
+	c.patchLoop(l, next, end)
+	return nil
+}
+
+// compileForInStatement keeps the iterator on the stack while the loop runs,
+// breaks jump to the OpPop removing it
+func (c *Compiler) compileForInStatement(node *ast.ForInStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	defer func() { c.symbolTable = c.symbolTable.Outer }()
+
+	l, leave := c.enterLoop()
+	defer leave()
+
+	start := len(c.currentInstructions())
+	if node.Key != nil {
+		c.emit(code.OpIterNext, 9999, 2)
+		c.setSymbol(c.symbolTable.Define(node.Value.Value))
+		c.setSymbol(c.symbolTable.Define(node.Key.Value))
+	} else {
+		c.emit(code.OpIterNext, 9999, 1)
+		c.setSymbol(c.symbolTable.Define(node.Value.Value))
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+
+	end := len(c.currentInstructions())
+	c.changeOperand(start, end)
+	c.patchLoop(l, start, end)
+	c.emit(code.OpPop)
+	return nil
+}
+
+// compileTryStatement protects the try block with a handler, an error jumps
+// to the catch block with the error on the stack. The finally block follows
+// the try and catch blocks, and is also run before throwing again an error
+// raised in the catch block, or one not caught at all
+func (c *Compiler) compileTryStatement(node *ast.TryStatement) error {
+	tryPos := c.emit(code.OpTry, 9999)
+	if err := c.compileTryBlock(node.Block, node.Finally); err != nil {
+		return err
+	}
+	c.emit(code.OpEndTry)
+	if err := c.compileFinally(node.Finally); err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(tryPos, len(c.currentInstructions()))
+	if node.Catch == nil {
+		if err := c.compileFinally(node.Finally); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	} else if err := c.compileCatch(node); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileCatch binds the error on the stack to the catch parameter,
+// with a finally block the catch block is protected by another handler
+func (c *Compiler) compileCatch(node *ast.TryStatement) error {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	defer func() { c.symbolTable = c.symbolTable.Outer }()
+
+	if node.CatchParam != nil {
+		c.emit(code.OpCatch)
+		c.setSymbol(c.symbolTable.Define(node.CatchParam.Value))
+	} else {
+		c.emit(code.OpPop)
+	}
+
+	if node.Finally == nil {
+		return c.Compile(node.Catch)
+	}
+
+	tryPos := c.emit(code.OpTry, 9999)
+	if err := c.compileTryBlock(node.Catch, node.Finally); err != nil {
+		return err
+	}
+	c.emit(code.OpEndTry)
+	if err := c.compileFinally(node.Finally); err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(tryPos, len(c.currentInstructions()))
+	if err := c.compileFinally(node.Finally); err != nil {
+		return err
+	}
+	c.emit(code.OpThrow)
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileTryBlock compiles a block protected by a handler
+func (c *Compiler) compileTryBlock(block, finally *ast.BlockStatement) error {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	defer func() { c.symbolTable = c.symbolTable.Outer }()
+
+	scope := c.scopeIndex
+	t := &try{finally: finally, loops: len(c.scopes[scope].loops)}
+	c.scopes[scope].tries = append(c.scopes[scope].tries, t)
+	defer func() {
+		tries := c.scopes[scope].tries
+		c.scopes[scope].tries = tries[:len(tries)-1]
+	}()
+
+	return c.Compile(block)
+}
+
+func (c *Compiler) compileFinally(finally *ast.BlockStatement) error {
+	if finally == nil {
+		return nil
+	}
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+	defer func() { c.symbolTable = c.symbolTable.Outer }()
+
+	return c.Compile(finally)
+}
+
+// leaveTries ends the try blocks from depth on, innermost first, running their finally blocks
+func (c *Compiler) leaveTries(depth int) error {
+	tries := c.currentScope().tries
+	defer func() { c.scopes[c.scopeIndex].tries = tries }()
+
+	for i := len(tries) - 1; i >= depth; i-- {
+		c.emit(code.OpEndTry)
+
+		// a finally block is outside of its own try block
+		c.scopes[c.scopeIndex].tries = tries[:i]
+		if err := c.compileFinally(tries[i].finally); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loopTries is the depth of the first try block inside the innermost loop
+func (c *Compiler) loopTries() int {
+	scope := c.currentScope()
+	depth := len(scope.tries)
+	for depth > 0 && scope.tries[depth-1].loops == len(scope.loops) {
+		depth--
+	}
+	return depth
+}
+
+// enterLoop makes l the target of break and continue until leave is called
+func (c *Compiler) enterLoop() (l *loop, leave func()) {
+	scope := c.scopeIndex
+	l = &loop{}
+	c.scopes[scope].loops = append(c.scopes[scope].loops, l)
+
+	return l, func() {
+		loops := c.scopes[scope].loops
+		c.scopes[scope].loops = loops[:len(loops)-1]
+	}
+}
+
+// patchLoop points the continues of l to next and the breaks to end
+func (c *Compiler) patchLoop(l *loop, next, end int) {
+	for _, pos := range l.continues {
+		c.changeOperand(pos, next)
+	}
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, end)
+	}
+}
+
+func (c *Compiler) currentLoop(node ast.Statement) (*loop, error) {
+	loops := c.currentScope().loops
+	if len(loops) > 0 {
+		return loops[len(loops)-1], nil
+	}
+
+	keyword := object.BREAK_OBJ
+	if _, ok := node.(*ast.ContinueStatement); ok {
+		keyword = object.CONTINUE_OBJ
+	}
+	if c.scopeIndex > 0 {
+		return nil, c.errorf("invalid keyword inside function: %s", keyword)
+	}
+	return nil, c.errorf("invalid keyword outside loop: %s", keyword)
+}
+
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	operator := code.AssignOperatorIndex(node.Operator)
+	if operator < 0 {
+		return c.errorf("unknown operator %s", node.Operator)
+	}
+
+	switch left := node.Left.(type) {
+	case *ast.Identifier:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		symbol := c.resolve(left.Value)
+		if operator > 0 {
+			c.loadSymbol(symbol)
+			c.emit(code.OpCompoundAssign, operator)
+		}
+		switch symbol.Scope {
+		case GlobalScope:
+			c.emit(code.OpAssignGlobal, symbol.Index)
+		case LocalScope:
+			c.emit(code.OpAssignLocal, symbol.Index)
+		case FreeScope:
+			c.emit(code.OpAssignFree, symbol.Index)
+		}
+
+	case *ast.IndexExpression:
+		ident, ok := left.Left.(*ast.Identifier)
+		if !ok {
+			return c.errorf("invalid identifier using index")
+		}
+		c.loadSymbol(c.resolve(ident.Value))
+		if err := c.Compile(left.Index); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		name := c.addConstant(&object.String{Value: ident.Value})
+		c.emit(code.OpSetIndex, operator, name)
+
+	case *ast.SliceExpression:
+		ident, ok := left.Left.(*ast.Identifier)
+		if !ok {
+			return c.errorf("invalid identifier using slice")
+		}
+		c.loadSymbol(c.resolve(ident.Value))
+		if err := c.compileSliceBounds(left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpSetSlice, operator)
+
+	default:
+		return c.errorf("invalid identifier when assign value: %s", node.Left.String())
+	}
+	return nil
+}
+
+// compileSliceBounds pushes start, end and step of a slice, null for an omitted one
+func (c *Compiler) compileSliceBounds(node *ast.SliceExpression) error {
+	for _, bound := range []ast.Expression{node.Start, node.End, node.Step} {
+		if bound == nil {
+			c.emit(code.OpNull)
+			continue
+		}
+		if err := c.Compile(bound); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral, name string) error {
+	outer := c.position
+	c.position = node.Span().Start
+	defer func() { c.position = outer }()
+
+	c.enterScope()
+
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+	if node.Rest != nil {
+		c.symbolTable.Define(node.Rest.Value)
+	}
+
+	// default values are evaluated by the callee, when the argument is missing
+	numDefaults := 0
+	for i, d := range node.Defaults {
+		if d == nil {
+			continue
+		}
+		numDefaults++
+
+		pos := c.emit(code.OpSkipDefault, 9999, i)
+		if err := c.Compile(d); err != nil {
+			return err
+		}
+		c.emit(code.OpSetLocal, i)
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	if n := len(node.Body.Statements); n > 0 {
+		if _, ok := node.Body.Statements[n-1].(*ast.ExpressionStatement); ok {
+			c.replaceLastPopWith(code.OpReturnValue)
+		}
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.NumLocals
+	localNames := c.symbolTable.LocalNames()
+	freeNames := c.symbolTable.FreeNames()
+	instructions, positions := c.leaveScope()
+
+	fn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		NumDefaults:   numDefaults,
+		Rest:          node.Rest != nil,
+		Name:          name,
+		Literal:       node,
+		Positions:     positions,
+		LocalNames:    localNames,
+		FreeNames:     freeNames,
+	}
+
+	pos := c.emit(code.OpClosure, c.addConstant(fn), len(freeSymbols))
+	for _, s := range freeSymbols {
+		isLocal := 0
+		if s.Scope == LocalScope {
+			isLocal = 1
+		}
+		c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), byte(isLocal), byte(s.Index))
+	}
+	c.setLastInstruction(code.OpClosure, pos)
+
+	return nil
+}
+
+// resolve treats unknown names as globals, they are checked when the vm runs
+// so functions can refer to globals defined later and to builtins
+func (c *Compiler) resolve(name string) Symbol {
+	if symbol, ok := c.symbolTable.Resolve(name); ok {
+		return symbol
+	}
+	return c.symbolTable.Global().Define(name)
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	}
+}
+
+func (c *Compiler) errorf(format string, a ...any) error {
+	return &CompileError{Message: fmt.Sprintf(format, a...), Position: c.position}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	c.checkOperands(op, operands)
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+	c.setLastInstruction(op, pos)
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	scope := &c.scopes[c.scopeIndex]
+	posNewInstruction := len(scope.instructions)
+
+	n := len(scope.positions)
+	if n == 0 || scope.positions[n-1].Position != c.position {
+		scope.positions = append(scope.positions, object.SourcePosition{Offset: posNewInstruction, Position: c.position})
+	}
+
+	scope.instructions = append(scope.instructions, ins...)
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	scope := &c.scopes[c.scopeIndex]
+	scope.previousInstruction = scope.lastInstruction
+	scope.lastInstruction = EmittedInstruction{Opcode: op, Position: pos}
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+	return c.currentScope().lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	scope := &c.scopes[c.scopeIndex]
+	last := scope.lastInstruction
+
+	scope.instructions = scope.instructions[:last.Position]
+	scope.lastInstruction = scope.previousInstruction
+
+	// drop positions of the removed instruction
+	for len(scope.positions) > 0 && scope.positions[len(scope.positions)-1].Offset >= last.Position {
+		scope.positions = scope.positions[:len(scope.positions)-1]
+	}
+}
+
+func (c *Compiler) replaceLastPopWith(op code.Opcode) {
+	lastPos := c.currentScope().lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(op))
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = op
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+// changeOperand replaces the first operand of the instruction at opPos
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	ins := c.currentInstructions()
+	op := code.Opcode(ins[opPos])
+	def, _ := code.Lookup(byte(op))
+	operands, _ := code.ReadOperands(def, ins[opPos+1:])
+	operands[0] = operand
+	c.checkOperands(op, operands)
+
+	newInstruction := code.Make(op, operands...)
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+// checkOperands keeps an error for an operand wider than op allows, e.g. the jump over
+// a very long loop body or the 256th argument of a call. Compile returns it at the end
+func (c *Compiler) checkOperands(op code.Opcode, operands []int) {
+	if c.tooLarge != nil {
+		return
+	}
+	def, _ := code.Lookup(byte(op))
+	for i, operand := range operands {
+		if width := def.OperandWidths[i]; !code.Fits(operand, width) {
+			c.tooLarge = c.errorf("program too large: %s operand %d does not fit in %d byte(s)", def.Name, operand, width)
+			return
+		}
+	}
+}
+
+func (c *Compiler) currentScope() CompilationScope {
+	return c.scopes[c.scopeIndex]
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() (code.Instructions, []object.SourcePosition) {
+	scope := c.currentScope()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+
+	return scope.instructions, scope.positions
+}
+
+type Bytecode struct {
+	Main        *object.CompiledFunction
+	Constants   []object.Object
+	GlobalNames []string       // global names by index, to report undefined globals
+	Exports     map[string]int // global index by name exported with `export let`
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	scope := c.currentScope()
+	return &Bytecode{
+		Main: &object.CompiledFunction{
+			Instructions: scope.instructions,
+			NumLocals:    c.symbolTable.Global().NumLocals,
+			Positions:    scope.positions,
+			LocalNames:   c.symbolTable.Global().LocalNames(),
+		},
+		Constants:   c.constants,
+		GlobalNames: c.symbolTable.GlobalNames(),
+		Exports:     c.exports,
+	}
+}
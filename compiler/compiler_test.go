@@ -0,0 +1,706 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/labasubagia/interpreter/ast"
+	"github.com/labasubagia/interpreter/code"
+	"github.com/labasubagia/interpreter/lexer"
+	"github.com/labasubagia/interpreter/object"
+	"github.com/labasubagia/interpreter/parser"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []any
+	expectedInstructions []code.Instructions
+}
+
+// compiledFunction is an expected function constant
+type compiledFunction struct {
+	numLocals    int
+	instructions []code.Instructions
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "1; 2",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "2 % 1",
+			expectedConstants: []any{2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMod),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "-1 <= 2",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThanEqual),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "if (true) { 10 }; 3333;",
+			expectedConstants: []any{10, 3333},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "if (true) { 10 } else { 20 }",
+			expectedConstants: []any{10, 20},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 13),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLogicalExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "true && 1",
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthyOrPop, 7),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "false || 1 && 2",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpFalse),
+				code.Make(code.OpJumpTruthyOrPop, 13),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJumpNotTruthyOrPop, 13),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestGlobalLetAndAssign(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let x = 1; x = 2; x",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAssignGlobal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "let x = 1; x += 2;",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpCompoundAssign, 1),
+				code.Make(code.OpAssignGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "let a = [1]; a[0] -= 2",
+			expectedConstants: []any{1, 0, 2, "a"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSetIndex, 2, 3),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "let a = [1]; a[:1] = [2]",
+			expectedConstants: []any{1, 1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpNull),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSetSlice, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// unknown names are globals, checked by the vm
+			input:             "len",
+			expectedConstants: []any{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestWhileStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "while (true) { let x = 1; break; continue; }",
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 18),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetLocal, 0),
+				code.Make(code.OpJump, 18),
+				code.Make(code.OpJump, 0),
+				code.Make(code.OpJump, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestForStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "for (let i = 0; i < 2; i += 1) { continue; }",
+			expectedConstants: []any{0, 2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetLocal, 0),
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpJumpNotTruthy, 30),
+				code.Make(code.OpJump, 17),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpCompoundAssign, 1),
+				code.Make(code.OpAssignLocal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpJump, 5),
+				code.Make(code.OpNull),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "for (k, v in [1]) { break; }",
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpIter),
+				code.Make(code.OpIterNext, 21, 2),
+				code.Make(code.OpSetLocal, 0),
+				code.Make(code.OpSetLocal, 1),
+				code.Make(code.OpJump, 21),
+				code.Make(code.OpJump, 7),
+				code.Make(code.OpPop),
+				code.Make(code.OpNull),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestSpread(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "[1, ...[2], 3]",
+			expectedConstants: []any{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpConcat, 3),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "len(...[1])",
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpConcat, 1),
+				code.Make(code.OpCallSpread),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `"a ${1} b ${2}"`,
+			expectedConstants: []any{"a ", 1, " b ", 2, ""},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpInterpolate, 5),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "[1, 2, 3][1:]",
+			expectedConstants: []any{1, 2, 3, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpArray, 3),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpNull),
+				code.Make(code.OpNull),
+				code.Make(code.OpSlice),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             `"abc"[::-1]`,
+			expectedConstants: []any{"abc", 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpNull),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpMinus),
+				code.Make(code.OpSlice),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestTryStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "try { 1; } catch (e) { 2; }",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTry, 11),
+				// 0003
+				code.Make(code.OpConstant, 0),
+				// 0006
+				code.Make(code.OpPop),
+				// 0007
+				code.Make(code.OpEndTry),
+				// 0008
+				code.Make(code.OpJump, 18),
+				// 0011
+				code.Make(code.OpCatch),
+				// 0012
+				code.Make(code.OpSetLocal, 0),
+				// 0014
+				code.Make(code.OpConstant, 1),
+				// 0017
+				code.Make(code.OpPop),
+				// 0018
+				code.Make(code.OpNull),
+				// 0019
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "try { 1; } catch { 2; } finally { 3; }",
+			expectedConstants: []any{1, 3, 2, 3, 3},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTry, 15),
+				// 0003
+				code.Make(code.OpConstant, 0),
+				// 0006
+				code.Make(code.OpPop),
+				// 0007
+				code.Make(code.OpEndTry),
+				// 0008
+				code.Make(code.OpConstant, 1),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpJump, 36),
+				// 0015
+				code.Make(code.OpPop),
+				// 0016
+				code.Make(code.OpTry, 31),
+				// 0019
+				code.Make(code.OpConstant, 2),
+				// 0022
+				code.Make(code.OpPop),
+				// 0023
+				code.Make(code.OpEndTry),
+				// 0024
+				code.Make(code.OpConstant, 3),
+				// 0027
+				code.Make(code.OpPop),
+				// 0028
+				code.Make(code.OpJump, 36),
+				// 0031
+				code.Make(code.OpConstant, 4),
+				// 0034
+				code.Make(code.OpPop),
+				// 0035
+				code.Make(code.OpThrow),
+				// 0036
+				code.Make(code.OpNull),
+				// 0037
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn() { try { return 1; } finally { 2; } }",
+			expectedConstants: []any{
+				1,
+				2,
+				2,
+				2,
+				compiledFunction{0, []code.Instructions{
+					code.Make(code.OpTry, 20),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpEndTry),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpPop),
+					code.Make(code.OpReturnValue),
+					code.Make(code.OpEndTry),
+					code.Make(code.OpConstant, 2),
+					code.Make(code.OpPop),
+					code.Make(code.OpJump, 25),
+					code.Make(code.OpConstant, 3),
+					code.Make(code.OpPop),
+					code.Make(code.OpThrow),
+					code.Make(code.OpReturn),
+				}},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 4, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             `throw "boom";`,
+			expectedConstants: []any{"boom"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpThrow),
+				code.Make(code.OpReturn),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestImportExport(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `let m = import "math.newpl"; export let two = 2;`,
+			expectedConstants: []any{"math.newpl", 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpImport, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpReturn),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+
+	compiler := New()
+	if err := compiler.Compile(parse(`let a = 1; export let b = 2;`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	exports := compiler.Bytecode().Exports
+	if len(exports) != 1 || exports["b"] != 1 {
+		t.Errorf("wrong exports. want=map[b:1], got=%v", exports)
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "fn(a) { a }",
+			expectedConstants: []any{
+				compiledFunction{1, []code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				}},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn(a, b = a) { b }",
+			expectedConstants: []any{
+				compiledFunction{2, []code.Instructions{
+					code.Make(code.OpSkipDefault, 8, 1),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				}},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn() { let a = 1; }",
+			expectedConstants: []any{
+				1,
+				compiledFunction{1, []code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpReturn),
+				}},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: "fn(a) { fn() { a = 2 } }",
+			expectedConstants: []any{
+				2,
+				compiledFunction{0, []code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpAssignFree, 0),
+					code.Make(code.OpReturnValue),
+				}},
+				compiledFunction{1, []code.Instructions{
+					append(code.Make(code.OpClosure, 1, 1), 1, 0),
+					code.Make(code.OpReturnValue),
+				}},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"12 = 12;", "1:1: invalid identifier when assign value: 12"},
+		{"[1,2][0] = 12;", "1:1: invalid identifier using index"},
+		{"while (true) { return 1; }", "1:16: return statement unsupported if while-loop not inside a function"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		err := New().Compile(program)
+		if err == nil {
+			t.Errorf("expected compile error for %q", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+func TestOperandLimits(t *testing.T) {
+	var locals strings.Builder
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&locals, "let %c%c = 1; ", 'a'+i/26, 'a'+i%26)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1; while (true) { " + strings.Repeat("x; ", 20000) + "}", "1:12: program too large: OpJumpNotTruthy operand 80013 does not fit in 2 byte(s)"},
+		{"let f = fn() {}; f(" + strings.Repeat("1, ", 299) + "1)", "1:18: program too large: OpCall operand 300 does not fit in 1 byte(s)"},
+		{"[" + strings.Repeat("1, ", 69999) + "1]", "1:196610: program too large: OpConstant operand 65536 does not fit in 2 byte(s)"},
+		{"fn() { " + locals.String() + "}", "1:3116: program too large: OpSetLocal operand 256 does not fit in 1 byte(s)"},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parse(tt.input))
+		if err == nil {
+			t.Errorf("expected compile error for %.20q", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := compiler.Bytecode()
+
+		if err := testInstructions(tt.expectedInstructions, bytecode.Main.Instructions); err != nil {
+			t.Fatalf("testInstructions failed for %q: %s", tt.input, err)
+		}
+
+		if err := testConstants(tt.expectedConstants, bytecode.Constants); err != nil {
+			t.Fatalf("testConstants failed for %q: %s", tt.input, err)
+		}
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func testInstructions(expected []code.Instructions, actual code.Instructions) error {
+	concatted := code.Instructions{}
+	for _, ins := range expected {
+		concatted = append(concatted, ins...)
+	}
+
+	if actual.String() != concatted.String() {
+		return fmt.Errorf("wrong instructions.\nwant=%q\ngot =%q", concatted, actual)
+	}
+	return nil
+}
+
+func testConstants(expected []any, actual []object.Object) error {
+	if len(expected) != len(actual) {
+		return fmt.Errorf("wrong number of constants. got=%d, want=%d", len(actual), len(expected))
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok || integer.Value != int64(constant) {
+				return fmt.Errorf("constant %d - object is not Integer %d. got=%T (%+v)", i, constant, actual[i], actual[i])
+			}
+		case string:
+			str, ok := actual[i].(*object.String)
+			if !ok || str.Value != constant {
+				return fmt.Errorf("constant %d - object is not String %q. got=%T (%+v)", i, constant, actual[i], actual[i])
+			}
+		case compiledFunction:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				return fmt.Errorf("constant %d - not a function: %T", i, actual[i])
+			}
+			if fn.NumLocals != constant.numLocals {
+				return fmt.Errorf("constant %d - wrong number of locals. want=%d, got=%d", i, constant.numLocals, fn.NumLocals)
+			}
+			if err := testInstructions(constant.instructions, fn.Instructions); err != nil {
+				return fmt.Errorf("constant %d - %s", i, err)
+			}
+		}
+	}
+
+	return nil
+}
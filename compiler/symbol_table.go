@@ -0,0 +1,123 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+	FreeScope   SymbolScope = "FREE"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// SymbolTable is either the global table, a function table or a block table.
+// Block tables (e.g. a while body) allocate their locals in the enclosing function
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store       map[string]Symbol
+	function    *SymbolTable // table owning the local slots, itself for functions and globals
+	names       []string     // global names by index, only on the global table
+	localNames  []string     // local names by index, only on function tables
+	NumLocals   int
+	FreeSymbols []Symbol
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := &SymbolTable{store: make(map[string]Symbol)}
+	s.function = s
+	return s
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+func NewBlockSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.function = outer.function
+	return s
+}
+
+func (s *SymbolTable) isBlock() bool {
+	return s.function != s
+}
+
+func (s *SymbolTable) isGlobal() bool {
+	return s.Outer == nil
+}
+
+// Define returns the existing symbol when name is already defined in this table
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && symbol.Scope != FreeScope {
+		return symbol
+	}
+
+	var symbol Symbol
+	if s.isGlobal() {
+		symbol = Symbol{Name: name, Scope: GlobalScope, Index: len(s.names)}
+		s.names = append(s.names, name)
+	} else {
+		symbol = Symbol{Name: name, Scope: LocalScope, Index: s.function.NumLocals}
+		s.function.NumLocals++
+		s.function.localNames = append(s.function.localNames, name)
+	}
+
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if ok || s.isGlobal() {
+		return symbol, ok
+	}
+
+	symbol, ok = s.Outer.Resolve(name)
+	if !ok || symbol.Scope == GlobalScope || s.isBlock() {
+		return symbol, ok
+	}
+
+	return s.defineFree(symbol), true
+}
+
+// Global returns the outermost table
+func (s *SymbolTable) Global() *SymbolTable {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	return s
+}
+
+// GlobalNames returns global names by index, for use by the vm
+func (s *SymbolTable) GlobalNames() []string {
+	return s.Global().names
+}
+
+// LocalNames returns local names of the function by index
+func (s *SymbolTable) LocalNames() []string {
+	return s.function.localNames
+}
+
+func (s *SymbolTable) FreeNames() []string {
+	names := make([]string, len(s.FreeSymbols))
+	for i, symbol := range s.FreeSymbols {
+		names[i] = symbol.Name
+	}
+	return names
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Scope: FreeScope, Index: len(s.FreeSymbols) - 1}
+	s.store[original.Name] = symbol
+
+	return symbol
+}
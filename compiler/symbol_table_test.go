@@ -0,0 +1,65 @@
+package compiler
+
+import "testing"
+
+func TestDefineAndResolve(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("b")
+
+	block := NewBlockSymbolTable(local)
+	block.Define("c")
+
+	nested := NewEnclosedSymbolTable(block)
+	nested.Define("d")
+
+	tests := []struct {
+		table    *SymbolTable
+		name     string
+		expected Symbol
+	}{
+		{global, "a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{local, "a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{local, "b", Symbol{Name: "b", Scope: LocalScope, Index: 0}},
+		// block locals live in the slots of the enclosing function
+		{block, "b", Symbol{Name: "b", Scope: LocalScope, Index: 0}},
+		{block, "c", Symbol{Name: "c", Scope: LocalScope, Index: 1}},
+		{nested, "d", Symbol{Name: "d", Scope: LocalScope, Index: 0}},
+		{nested, "c", Symbol{Name: "c", Scope: FreeScope, Index: 0}},
+		{nested, "b", Symbol{Name: "b", Scope: FreeScope, Index: 1}},
+	}
+
+	for _, tt := range tests {
+		result, ok := tt.table.Resolve(tt.name)
+		if !ok {
+			t.Errorf("name %s not resolvable", tt.name)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", tt.name, tt.expected, result)
+		}
+	}
+
+	if local.NumLocals != 2 {
+		t.Errorf("wrong number of locals. want=2, got=%d", local.NumLocals)
+	}
+
+	expectedFree := []Symbol{
+		{Name: "c", Scope: LocalScope, Index: 1},
+		{Name: "b", Scope: LocalScope, Index: 0},
+	}
+	if len(nested.FreeSymbols) != len(expectedFree) {
+		t.Fatalf("wrong number of free symbols. want=%d, got=%d", len(expectedFree), len(nested.FreeSymbols))
+	}
+	for i, sym := range expectedFree {
+		if nested.FreeSymbols[i] != sym {
+			t.Errorf("wrong free symbol. want=%+v, got=%+v", sym, nested.FreeSymbols[i])
+		}
+	}
+
+	if _, ok := global.Resolve("b"); ok {
+		t.Errorf("name b resolvable in global scope")
+	}
+}
@@ -0,0 +1,737 @@
+package evaluator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labasubagia/interpreter/object"
+)
+
+// Builtins are the native functions available to scripts, by name.
+// Every Evaluator has its own, so hosts can register functions without affecting others
+type Builtins map[string]*object.Builtin
+
+// NewBuiltins returns a copy of the default builtins
+func NewBuiltins() Builtins {
+	b := make(Builtins, len(defaultBuiltins))
+	for _, builtin := range defaultBuiltins {
+		b[builtin.Name] = builtin
+	}
+	return b
+}
+
+// Register adds builtin, replacing the one with the same name
+func (b Builtins) Register(builtin *object.Builtin) error {
+	if builtin.Name == "" {
+		return fmt.Errorf("builtin name is required")
+	}
+	if builtin.Fn == nil {
+		return fmt.Errorf("builtin %s has no function", builtin.Name)
+	}
+	if builtin.Variadic && len(builtin.Params) == 0 {
+		return fmt.Errorf("variadic builtin %s needs at least one parameter", builtin.Name)
+	}
+	b[builtin.Name] = builtin
+	return nil
+}
+
+// Names returns the sorted names of the builtins
+func (b Builtins) Names() []string {
+	names := make([]string, 0, len(b))
+	for name := range b {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var defaultBuiltins = []*object.Builtin{
+	{
+		Name:   "len",
+		Doc:    "len(x) returns the number of elements of an array, tuple or hash, or the number of characters of a string",
+		Params: []object.ObjectType{object.ANY_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			switch arg := args[0].(type) {
+			case *object.Array:
+				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Tuple:
+				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.String:
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(len(arg.Pairs))}
+			default:
+				return object.NewError(object.TYPE_ERROR, "argument to `len` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	{
+		Name:   "first",
+		Doc:    "first(arr) returns the first element of arr, or null when it is empty",
+		Params: []object.ObjectType{object.ARRAY_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			arr := args[0].(*object.Array)
+			if len(arr.Elements) > 0 {
+				return arr.Elements[0]
+			}
+			return NULL
+		},
+	},
+	{
+		Name:   "last",
+		Doc:    "last(arr) returns the last element of arr, or null when it is empty",
+		Params: []object.ObjectType{object.ARRAY_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			arr := args[0].(*object.Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				return arr.Elements[length-1]
+			}
+			return NULL
+		},
+	},
+	{
+		Name:   "rest",
+		Doc:    "rest(arr) returns a new array with every element of arr but the first",
+		Params: []object.ObjectType{object.ARRAY_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			arr := args[0].(*object.Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				newElements := make([]object.Object, length-1)
+				copy(newElements, arr.Elements[1:length])
+				return &object.Array{Elements: newElements}
+			}
+
+			return &object.Array{Elements: []object.Object{}}
+		},
+	},
+	{
+		Name:   "push",
+		Doc:    "push(arr, x) returns a new array with the elements of arr followed by x",
+		Params: []object.ObjectType{object.ARRAY_OBJ, object.ANY_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			arr := args[0].(*object.Array)
+			length := len(arr.Elements)
+			newElements := make([]object.Object, length+1)
+			copy(newElements, arr.Elements)
+			newElements[length] = args[1]
+			return &object.Array{Elements: newElements}
+		},
+	},
+	{
+		Name:   "map",
+		Doc:    "map(arr, f) returns a new array with the result of f for every element of arr",
+		Params: []object.ObjectType{object.ARRAY_OBJ, object.ANY_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			arr := args[0].(*object.Array)
+			elements := make([]object.Object, len(arr.Elements))
+			for i, el := range arr.Elements {
+				result := ctx.Call(args[1], el)
+				if isError(result) {
+					return result
+				}
+				elements[i] = result
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	{
+		Name:   "filter",
+		Doc:    "filter(arr, f) returns a new array with the elements of arr for which f is truthy",
+		Params: []object.ObjectType{object.ARRAY_OBJ, object.ANY_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			elements := []object.Object{}
+			for _, el := range args[0].(*object.Array).Elements {
+				result := ctx.Call(args[1], el)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					elements = append(elements, el)
+				}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	{
+		Name:     "reduce",
+		Doc:      "reduce(arr, f, initial...) combines the elements of arr with f(accumulated, element), starting from initial or the first element",
+		Params:   []object.ObjectType{object.ARRAY_OBJ, object.ANY_OBJ, object.ANY_OBJ},
+		Variadic: true,
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			if len(args) > 3 {
+				return object.NewError(object.TYPE_ERROR, "wrong number of arguments. got=%d, want=2 or 3", len(args))
+			}
+			elements := args[0].(*object.Array).Elements
+
+			var accumulated object.Object
+			if len(args) == 3 {
+				accumulated = args[2]
+			} else if len(elements) > 0 {
+				accumulated, elements = elements[0], elements[1:]
+			} else {
+				return object.NewError(object.VALUE_ERROR, "reduce of empty array with no initial value")
+			}
+
+			for _, el := range elements {
+				accumulated = ctx.Call(args[1], accumulated, el)
+				if isError(accumulated) {
+					return accumulated
+				}
+			}
+			return accumulated
+		},
+	},
+	{
+		Name:   "any",
+		Doc:    "any(arr, f) returns whether f is truthy for an element of arr",
+		Params: []object.ObjectType{object.ARRAY_OBJ, object.ANY_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			for _, el := range args[0].(*object.Array).Elements {
+				result := ctx.Call(args[1], el)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					return TRUE
+				}
+			}
+			return FALSE
+		},
+	},
+	{
+		Name:   "all",
+		Doc:    "all(arr, f) returns whether f is truthy for every element of arr",
+		Params: []object.ObjectType{object.ARRAY_OBJ, object.ANY_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			for _, el := range args[0].(*object.Array).Elements {
+				result := ctx.Call(args[1], el)
+				if isError(result) {
+					return result
+				}
+				if !isTruthy(result) {
+					return FALSE
+				}
+			}
+			return TRUE
+		},
+	},
+	{
+		Name:   "find",
+		Doc:    "find(arr, f) returns the first element of arr for which f is truthy, or null when there is none",
+		Params: []object.ObjectType{object.ARRAY_OBJ, object.ANY_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			for _, el := range args[0].(*object.Array).Elements {
+				result := ctx.Call(args[1], el)
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					return el
+				}
+			}
+			return NULL
+		},
+	},
+	{
+		Name:     "sort",
+		Doc:      "sort(arr, compare...) returns a new array with the numbers or strings of arr in ascending order, or ordered by compare(a, b) returning a negative number when a comes first",
+		Params:   []object.ObjectType{object.ARRAY_OBJ, object.ANY_OBJ},
+		Variadic: true,
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			if len(args) > 2 {
+				return object.NewError(object.TYPE_ERROR, "wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			elements := make([]object.Object, len(args[0].(*object.Array).Elements))
+			copy(elements, args[0].(*object.Array).Elements)
+
+			var err *object.Error
+			sort.SliceStable(elements, func(i, j int) bool {
+				if err != nil {
+					return false
+				}
+				var less bool
+				if len(args) == 2 {
+					less, err = compareWith(ctx, args[1], elements[i], elements[j])
+				} else {
+					less, err = lessThan(elements[i], elements[j])
+				}
+				return less
+			})
+			if err != nil {
+				return err
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	{
+		Name:   "reverse",
+		Doc:    "reverse(arr) returns a new array with the elements of arr in reverse order",
+		Params: []object.ObjectType{object.ARRAY_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			arr := args[0].(*object.Array)
+			elements := make([]object.Object, len(arr.Elements))
+			for i, el := range arr.Elements {
+				elements[len(elements)-1-i] = el
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	{
+		Name:   "keys",
+		Doc:    "keys(h) returns the keys of h in insertion order",
+		Params: []object.ObjectType{object.HASH_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			pairs := args[0].(*object.Hash).Ordered()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Key
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	{
+		Name:   "values",
+		Doc:    "values(h) returns the values of h in insertion order",
+		Params: []object.ObjectType{object.HASH_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			pairs := args[0].(*object.Hash).Ordered()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Value
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	{
+		Name:   "entries",
+		Doc:    "entries(h) returns the [key, value] pairs of h in insertion order",
+		Params: []object.ObjectType{object.HASH_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			pairs := args[0].(*object.Hash).Ordered()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	{
+		Name:   "has",
+		Doc:    "has(h, key) reports whether h has key, also when its value is null or false",
+		Params: []object.ObjectType{object.HASH_OBJ, object.ANY_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			key, err := hashKey(args[1])
+			if err != nil {
+				return err
+			}
+			_, ok := args[0].(*object.Hash).Pairs[key]
+			return nativeBoolToBooleanObject(ok)
+		},
+	},
+	{
+		Name:     "get",
+		Doc:      "get(h, key, default...) returns the value of key in h, or default, or null when h does not have key",
+		Params:   []object.ObjectType{object.HASH_OBJ, object.ANY_OBJ, object.ANY_OBJ},
+		Variadic: true,
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			if len(args) > 3 {
+				return object.NewError(object.TYPE_ERROR, "wrong number of arguments. got=%d, want=2 or 3", len(args))
+			}
+			key, err := hashKey(args[1])
+			if err != nil {
+				return err
+			}
+			if pair, ok := args[0].(*object.Hash).Pairs[key]; ok {
+				return pair.Value
+			}
+			if len(args) == 3 {
+				return args[2]
+			}
+			return NULL
+		},
+	},
+	{
+		Name:   "delete",
+		Doc:    "delete(h, key) removes key from h and returns its value, or null when h does not have key",
+		Params: []object.ObjectType{object.HASH_OBJ, object.ANY_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			key, err := hashKey(args[1])
+			if err != nil {
+				return err
+			}
+			if pair, ok := args[0].(*object.Hash).Delete(key); ok {
+				return pair.Value
+			}
+			return NULL
+		},
+	},
+	{
+		Name:     "merge",
+		Doc:      "merge(hashes...) returns a new hash with the pairs of every hash, a key of a later hash replaces the value of an earlier one",
+		Params:   []object.ObjectType{object.HASH_OBJ},
+		Variadic: true,
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			merged := object.NewHash()
+			for _, arg := range args {
+				for _, pair := range arg.(*object.Hash).Ordered() {
+					merged.Set(pair.Key.(object.Hashable).HashKey(), pair)
+				}
+			}
+			return merged
+		},
+	},
+	{
+		Name:     "tuple",
+		Doc:      "tuple(elements...) returns an immutable tuple of the elements, it can be a hash key. Arrays among the elements become tuples",
+		Params:   []object.ObjectType{object.ANY_OBJ},
+		Variadic: true,
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			for _, arg := range args {
+				if _, _, ok := object.HashKeyOf(arg); !ok {
+					return object.NewError(object.TYPE_ERROR, "unusable as tuple element: %s", arg.Type())
+				}
+			}
+			tuple, _ := object.NewTuple(args)
+			return tuple
+		},
+	},
+	{
+		Name:     "split",
+		Doc:      "split(s, sep...) returns the parts of s between each sep, or between runs of whitespace when there is no sep",
+		Params:   []object.ObjectType{object.STRING_OBJ, object.STRING_OBJ},
+		Variadic: true,
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			if len(args) > 2 {
+				return object.NewError(object.TYPE_ERROR, "wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			s := args[0].(*object.String).Value
+
+			var parts []string
+			if len(args) == 1 {
+				parts = strings.Fields(s)
+			} else {
+				parts = strings.Split(s, args[1].(*object.String).Value)
+			}
+			elements := make([]object.Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &object.String{Value: part}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	{
+		Name:     "join",
+		Doc:      "join(arr, sep...) returns the strings of arr joined by sep, or by nothing when there is no sep",
+		Params:   []object.ObjectType{object.ARRAY_OBJ, object.STRING_OBJ},
+		Variadic: true,
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			if len(args) > 2 {
+				return object.NewError(object.TYPE_ERROR, "wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			sep := ""
+			if len(args) == 2 {
+				sep = args[1].(*object.String).Value
+			}
+
+			elements := args[0].(*object.Array).Elements
+			parts := make([]string, len(elements))
+			for i, el := range elements {
+				str, ok := el.(*object.String)
+				if !ok {
+					return object.NewError(object.TYPE_ERROR, "elements of `join` must be STRING, got %s", el.Type())
+				}
+				parts[i] = str.Value
+			}
+			return &object.String{Value: strings.Join(parts, sep)}
+		},
+	},
+	{
+		Name:     "trim",
+		Doc:      "trim(s, cutset...) returns s without leading and trailing whitespace, or characters of cutset",
+		Params:   []object.ObjectType{object.STRING_OBJ, object.STRING_OBJ},
+		Variadic: true,
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			if len(args) > 2 {
+				return object.NewError(object.TYPE_ERROR, "wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+			s := args[0].(*object.String).Value
+			if len(args) == 1 {
+				return &object.String{Value: strings.TrimSpace(s)}
+			}
+			return &object.String{Value: strings.Trim(s, args[1].(*object.String).Value)}
+		},
+	},
+	{
+		Name:   "replace",
+		Doc:    "replace(s, old, new) returns s with every old replaced by new",
+		Params: []object.ObjectType{object.STRING_OBJ, object.STRING_OBJ, object.STRING_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			s, old, new := args[0].(*object.String), args[1].(*object.String), args[2].(*object.String)
+			return &object.String{Value: strings.ReplaceAll(s.Value, old.Value, new.Value)}
+		},
+	},
+	{
+		Name:   "upper",
+		Doc:    "upper(s) returns s in upper case",
+		Params: []object.ObjectType{object.STRING_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			return &object.String{Value: strings.ToUpper(args[0].(*object.String).Value)}
+		},
+	},
+	{
+		Name:   "lower",
+		Doc:    "lower(s) returns s in lower case",
+		Params: []object.ObjectType{object.STRING_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			return &object.String{Value: strings.ToLower(args[0].(*object.String).Value)}
+		},
+	},
+	{
+		Name:   "contains",
+		Doc:    "contains(s, sub) reports whether sub is within s",
+		Params: []object.ObjectType{object.STRING_OBJ, object.STRING_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			return nativeBoolToBooleanObject(strings.Contains(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	},
+	{
+		Name:   "starts_with",
+		Doc:    "starts_with(s, prefix) reports whether s begins with prefix",
+		Params: []object.ObjectType{object.STRING_OBJ, object.STRING_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			return nativeBoolToBooleanObject(strings.HasPrefix(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	},
+	{
+		Name:   "ends_with",
+		Doc:    "ends_with(s, suffix) reports whether s ends with suffix",
+		Params: []object.ObjectType{object.STRING_OBJ, object.STRING_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			return nativeBoolToBooleanObject(strings.HasSuffix(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	},
+	{
+		Name:   "index_of",
+		Doc:    "index_of(s, sub) returns the index of the first character of sub in s, or -1 when s does not contain it",
+		Params: []object.ObjectType{object.STRING_OBJ, object.STRING_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			s := args[0].(*object.String).Value
+			i := strings.Index(s, args[1].(*object.String).Value)
+			if i < 0 {
+				return &object.Integer{Value: -1}
+			}
+			return &object.Integer{Value: int64(utf8.RuneCountInString(s[:i]))}
+		},
+	},
+	{
+		Name:   "repeat",
+		Doc:    "repeat(s, n) returns s repeated n times",
+		Params: []object.ObjectType{object.STRING_OBJ, object.INTEGER_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			s := args[0].(*object.String).Value
+			n, ok := object.Int64(args[1])
+			if !ok {
+				return object.NewError(object.VALUE_ERROR, "count of `repeat` is out of range, got %s", args[1].Inspect())
+			}
+			if n < 0 {
+				return object.NewError(object.VALUE_ERROR, "count of `repeat` must not be negative, got %d", n)
+			}
+			if len(s) > 0 && n > int64(math.MaxInt32/len(s)) {
+				return object.NewError(object.VALUE_ERROR, "result of `repeat` is too long")
+			}
+			return &object.String{Value: strings.Repeat(s, int(n))}
+		},
+	},
+	{
+		Name:     "substr",
+		Doc:      "substr(s, start, length...) returns length characters of s from start, or the rest of s when there is no length. A negative start counts from the end",
+		Params:   []object.ObjectType{object.STRING_OBJ, object.INTEGER_OBJ, object.INTEGER_OBJ},
+		Variadic: true,
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			if len(args) > 3 {
+				return object.NewError(object.TYPE_ERROR, "wrong number of arguments. got=%d, want=2 or 3", len(args))
+			}
+			runes := []rune(args[0].(*object.String).Value)
+			size := int64(len(runes))
+
+			start, ok := object.Int64(args[1])
+			if !ok {
+				return object.NewError(object.VALUE_ERROR, "start of `substr` is out of range, got %s", args[1].Inspect())
+			}
+			if start < 0 {
+				start = max(size+start, 0)
+			}
+			start = min(start, size)
+
+			end := size
+			if len(args) == 3 {
+				length, ok := object.Int64(args[2])
+				if !ok {
+					return object.NewError(object.VALUE_ERROR, "length of `substr` is out of range, got %s", args[2].Inspect())
+				}
+				if length < 0 {
+					return object.NewError(object.VALUE_ERROR, "length of `substr` must not be negative, got %d", length)
+				}
+				end = min(start+min(length, size), size)
+			}
+			return &object.String{Value: string(runes[start:end])}
+		},
+	},
+	{
+		Name:     "format",
+		Doc:      "format(f, args...) returns args formatted by the printf style verbs of f, e.g. %s, %d, %.2f, %q, %x, %t and %v",
+		Params:   []object.ObjectType{object.STRING_OBJ, object.ANY_OBJ},
+		Variadic: true,
+		Fn:       formatBuiltin,
+	},
+	{
+		Name:     "sprintf",
+		Doc:      "sprintf(f, args...) is the same as format",
+		Params:   []object.ObjectType{object.STRING_OBJ, object.ANY_OBJ},
+		Variadic: true,
+		Fn:       formatBuiltin,
+	},
+	{
+		Name:   "int",
+		Doc:    "int(x) converts a float, truncating toward zero, or a string to an integer",
+		Params: []object.ObjectType{object.ANY_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.BigInteger:
+				return arg
+			case *object.Float:
+				if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+					return object.NewError(object.VALUE_ERROR, "cannot convert %s to INTEGER", arg.Inspect())
+				}
+				value, _ := big.NewFloat(arg.Value).Int(nil)
+				return object.NewInteger(value)
+			case *object.String:
+				value, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 10)
+				if !ok {
+					return object.NewError(object.VALUE_ERROR, "cannot convert %q to INTEGER", arg.Value)
+				}
+				return object.NewInteger(value)
+			default:
+				return object.NewError(object.TYPE_ERROR, "argument to `int` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	{
+		Name:   "float",
+		Doc:    "float(x) converts an integer or a string to a float",
+		Params: []object.ObjectType{object.ANY_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.BigInteger:
+				return &object.Float{Value: toFloat(arg)}
+			case *object.Float:
+				return arg
+			case *object.String:
+				value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+				if err != nil {
+					return object.NewError(object.VALUE_ERROR, "cannot convert %q to FLOAT", arg.Value)
+				}
+				return &object.Float{Value: value}
+			default:
+				return object.NewError(object.TYPE_ERROR, "argument to `float` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	{
+		Name:     "puts",
+		Doc:      "puts(args...) prints the arguments separated by spaces, followed by a newline",
+		Params:   []object.ObjectType{object.ANY_OBJ},
+		Variadic: true,
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			var out bytes.Buffer
+			for i, arg := range args {
+				out.WriteString(arg.Inspect())
+				if i < len(args)-1 {
+					out.WriteString(" ")
+				}
+			}
+
+			fmt.Fprintln(ctx.Stdout, out.String())
+			return NULL
+		},
+	},
+	{
+		Name:     "input",
+		Doc:      "input(prompt...) prints the prompt and returns the next line of input, or null when there is none",
+		Params:   []object.ObjectType{object.STRING_OBJ},
+		Variadic: true,
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Fprint(ctx.Stdout, arg.(*object.String).Value)
+			}
+
+			line, err := ctx.ReadLine()
+			if err == io.EOF {
+				return NULL
+			}
+			if err != nil {
+				return newError("cannot read input: %s", err)
+			}
+			return &object.String{Value: line}
+		},
+	},
+}
+
+// hashKey is the key of obj in a hash
+func hashKey(obj object.Object) (object.HashKey, *object.Error) {
+	_, key, ok := object.HashKeyOf(obj)
+	if !ok {
+		return object.HashKey{}, object.NewError(object.TYPE_ERROR, "unusable as hash key: %s", obj.Type())
+	}
+	return key, nil
+}
+
+func formatBuiltin(ctx *object.ExecContext, args ...object.Object) object.Object {
+	s, err := formatObjects(args[0].(*object.String).Value, args[1:])
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: s}
+}
+
+// lessThan orders numbers and strings for sort
+func lessThan(a, b object.Object) (bool, *object.Error) {
+	if isNumber(a) && isNumber(b) {
+		return isTruthy(evalInfixExpression("<", a, b)), nil
+	}
+	if a, ok := a.(*object.String); ok {
+		if b, ok := b.(*object.String); ok {
+			return a.Value < b.Value, nil
+		}
+	}
+	return false, object.NewError(object.TYPE_ERROR, "cannot compare %s and %s", a.Type(), b.Type())
+}
+
+// compareWith orders a and b with the comparator of sort
+func compareWith(ctx *object.ExecContext, compare, a, b object.Object) (bool, *object.Error) {
+	result := ctx.Call(compare, a, b)
+	switch result := result.(type) {
+	case *object.Error:
+		return false, result
+	case *object.Integer:
+		return result.Value < 0, nil
+	case *object.Float:
+		return result.Value < 0, nil
+	case *object.BigInteger:
+		return result.Value.Sign() < 0, nil
+	default:
+		return false, object.NewError(object.TYPE_ERROR, "comparator of `sort` must return a number, got %s", result.Type())
+	}
+}
+
+// builtins are the defaults, used when no Builtins are given
+var builtins = NewBuiltins()
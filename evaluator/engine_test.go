@@ -0,0 +1,42 @@
+package evaluator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/labasubagia/interpreter/evaluator"
+	"github.com/labasubagia/interpreter/internal/enginetest"
+	"github.com/labasubagia/interpreter/object"
+)
+
+var engine = enginetest.Engine{
+	Run: func(input string) object.Object {
+		program, err := enginetest.Parse(input)
+		if err != nil {
+			return err
+		}
+		return evaluator.Eval(program, object.NewEnvironment(), evaluator.ScopeNone)
+	},
+	RunFile: func(input, file string, modules *evaluator.Modules) object.Object {
+		program, err := enginetest.Parse(input)
+		if err != nil {
+			return err
+		}
+
+		e := evaluator.New()
+		e.SetModules(modules)
+		e.SetFile(file)
+		return e.Eval(program, object.NewEnvironment(), evaluator.ScopeNone)
+	},
+	RunContext: func(ctx context.Context, input string, limits evaluator.Limits) object.Object {
+		program, err := enginetest.Parse(input)
+		if err != nil {
+			return err
+		}
+		return evaluator.EvalContext(ctx, program, object.NewEnvironment(), evaluator.ScopeNone, limits)
+	},
+}
+
+func TestEngine(t *testing.T) {
+	enginetest.Run(t, engine)
+}
@@ -1,11 +1,15 @@
 package evaluator
 
 import (
-	"bytes"
 	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/labasubagia/interpreter/ast"
 	"github.com/labasubagia/interpreter/object"
+	"github.com/labasubagia/interpreter/token"
 )
 
 type ScopeType int
@@ -15,6 +19,7 @@ const (
 	ScopeNone
 	ScopeFunction
 	ScopeLoop
+	ScopeFunctionLoop // a loop nested anywhere inside a function, a return in it leaves the function
 )
 
 var (
@@ -25,196 +30,197 @@ var (
 	CONTINUE = &object.Continue{}
 )
 
-var builtins = map[string]*object.Builtin{
-	"len": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-			switch arg := args[0].(type) {
-			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
-			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
-			case *object.Hash:
-				return &object.Integer{Value: int64(len(arg.Pairs))}
-			default:
-				return newError("argument to `len` not supported, got %s", args[0].Type())
-			}
-		},
-	},
-	"first": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
-			}
-			arr := args[0].(*object.Array)
-			if len(arr.Elements) > 0 {
-				return arr.Elements[0]
-			}
-			return NULL
-		},
-	},
-	"last": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
-			}
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				return arr.Elements[length-1]
-			}
-			return NULL
-		},
-	},
-	"rest": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
-			}
+// Evaluator holds the state of a single evaluation
+type Evaluator struct {
+	frames   []object.StackFrame // active function calls, outermost first
+	builtins Builtins
+	ctx      *object.ExecContext
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				newElements := make([]object.Object, length-1)
-				copy(newElements, arr.Elements[1:length])
-				return &object.Array{Elements: newElements}
-			}
+	limits      Limits
+	done        <-chan struct{} // closed when the evaluation must stop
+	steps       int
+	allocations int
 
-			return &object.Array{Elements: []object.Object{}}
-		},
-	},
-	"push": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2", len(args))
-			}
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
-			}
+	modules *Modules
+	file    string   // file being evaluated, imports are resolved from it
+	exports []string // names exported by the module being evaluated
+}
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			newElements := make([]object.Object, length+1)
-			copy(newElements, arr.Elements)
-			newElements[length] = args[1]
-			return &object.Array{Elements: newElements}
-		},
-	},
-	"puts": {
-		Fn: func(args ...object.Object) object.Object {
-			var out bytes.Buffer
-			for i, arg := range args {
-				out.WriteString(arg.Inspect())
-				if i < len(args)-1 {
-					out.WriteString(" ")
-				}
-			}
+func New() *Evaluator {
+	return NewWithBuiltins(builtins)
+}
 
-			fmt.Println(out.String())
-			return NULL
-		},
-	},
+func NewWithBuiltins(builtins Builtins) *Evaluator {
+	return &Evaluator{builtins: builtins, ctx: object.NewExecContext(), modules: NewModules()}
+}
+
+// SetModules replaces the modules available to imports, e.g. to share them or change the search path
+func (e *Evaluator) SetModules(modules *Modules) {
+	e.modules = modules
+}
+
+// SetFile sets the file of the evaluated program, imports are resolved relative to it
+func (e *Evaluator) SetFile(path string) {
+	e.file = path
+}
+
+// SetContext replaces the context passed to builtins, e.g. to capture their output
+func (e *Evaluator) SetContext(ctx *object.ExecContext) {
+	e.ctx = ctx
 }
 
+// Eval evaluates node using a new Evaluator
 func Eval(node ast.Node, env *object.Environment, scope ScopeType) object.Object {
+	return New().Eval(node, env, scope)
+}
+
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment, scope ScopeType) object.Object {
+	if err := e.account(node); err != nil {
+		err.File, err.Position = e.file, node.Span().Start
+		return err
+	}
+
+	obj := e.eval(node, env, scope)
+	if err, ok := obj.(*object.Error); ok && !err.Position.IsValid() {
+		err.File, err.Position = e.file, node.Span().Start
+	}
+	return obj
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment, scope ScopeType) object.Object {
 
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node, env, scope)
+		return e.evalProgram(node, env, scope)
 	case *ast.LetStatement:
-		val := Eval(node.Value, env, scope)
+		val := e.Eval(node.Value, env, scope)
 		if isError(val) {
 			return val
 		}
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			fn.Name = node.Name.Value
+		}
 		env.Set(node.Name.Value, val)
 	case *ast.AssignExpression:
-		return evalAssignExpression(node, env, scope)
+		return e.evalAssignExpression(node, env, scope)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env, scope)
+		return e.Eval(node.Expression, env, scope)
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env, scope)
+		right := e.Eval(node.Right, env, scope)
 		if isError(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env, scope)
+		left := e.Eval(node.Left, env, scope)
 		if isError(left) {
 			return left
 		}
-		right := Eval(node.Right, env, scope)
+		right := e.Eval(node.Right, env, scope)
 		if isError(right) {
 			return right
 		}
 		return evalInfixExpression(node.Operator, left, right)
+	case *ast.LogicalExpression:
+		return e.evalLogicalExpression(node, env, scope)
 
 	case *ast.WhileStatement:
-		return evalWhileStatement(node, env, scope)
+		return e.evalWhileStatement(node, env, scope)
+	case *ast.ForStatement:
+		return e.evalForStatement(node, env, scope)
+	case *ast.ForInStatement:
+		return e.evalForInStatement(node, env, scope)
+	case *ast.TryStatement:
+		return e.evalTryStatement(node, env, scope)
+	case *ast.ThrowStatement:
+		val := e.Eval(node.Value, env, scope)
+		if isError(val) {
+			return val
+		}
+		return throwError(val)
+	case *ast.ImportExpression:
+		return e.importModule(node.Path.Value, node.Span().Start)
+	case *ast.ExportStatement:
+		val := e.Eval(node.Statement, env, scope)
+		if isError(val) {
+			return val
+		}
+		e.exports = append(e.exports, node.Statement.Name.Value)
 	case *ast.BreakStatement:
 		return BREAK
 	case *ast.ContinueStatement:
 		return CONTINUE
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInteger{Value: node.Big}
+		}
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env, scope)
+		return e.evalIfExpression(node, env, scope)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env, scope)
+		return e.evalBlockStatement(node, env, scope)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env, scope)
+		val := e.Eval(node.ReturnValue, env, scope)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{Parameters: params, Defaults: node.Defaults, Rest: node.Rest, Body: body, Env: env, File: e.file}
 	case *ast.CallExpression:
-		function := Eval(node.Function, env, scope)
+		function := e.Eval(node.Function, env, scope)
 		if isError(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env, scope)
+		args := e.evalExpressions(node.Arguments, env, scope)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return e.applyFunction(function, args, node.Span().Start)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		parts := e.evalExpressions(node.Parts, env, scope)
+		if len(parts) == 1 && isError(parts[0]) {
+			return parts[0]
+		}
+		return interpolate(parts)
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env, scope)
+		elements := e.evalExpressions(node.Elements, env, scope)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env, scope)
+		left := e.Eval(node.Left, env, scope)
 		if isError(left) {
 			return left
 		}
-		index := Eval(node.Index, env, scope)
+		index := e.Eval(node.Index, env, scope)
 		if isError(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		left := e.Eval(node.Left, env, scope)
+		if isError(left) {
+			return left
+		}
+		bounds := e.evalSliceBounds(node, env, scope)
+		if len(bounds) == 1 && isError(bounds[0]) {
+			return bounds[0]
+		}
+		return evalSliceExpression(left, bounds[0], bounds[1], bounds[2])
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env, scope)
+		return e.evalHashLiteral(node, env, scope)
 	case *ast.Null:
 		return NULL
 	}
@@ -222,45 +228,152 @@ func Eval(node ast.Node, env *object.Environment, scope ScopeType) object.Object
 	return nil
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment, scope ScopeType) []object.Object {
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment, scope ScopeType) []object.Object {
 	var result []object.Object
 
-	for _, e := range exps {
-		evaluated := Eval(e, env, scope)
+	for _, exp := range exps {
+		spread, isSpread := exp.(*ast.SpreadExpression)
+		if isSpread {
+			exp = spread.Value
+		}
+
+		evaluated := e.Eval(exp, env, scope)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
-		result = append(result, evaluated)
+
+		if !isSpread {
+			result = append(result, evaluated)
+			continue
+		}
+		arr, ok := evaluated.(*object.Array)
+		if !ok {
+			return []object.Object{object.NewError(object.TYPE_ERROR, "spread operator not supported: %s", evaluated.Type())}
+		}
+		result = append(result, arr.Elements...)
 	}
 
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// Apply calls fn with args outside of a script, e.g. from a host program
+func (e *Evaluator) Apply(fn object.Object, args []object.Object) object.Object {
+	return e.applyFunction(fn, args, token.Position{})
+}
+
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object, pos token.Position) object.Object {
 
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv, ScopeFunction)
+		if len(e.frames) >= e.limits.Depth() {
+			return object.NewError(object.LIMIT_ERROR, LimitExceeded)
+		}
+		if err := checkArity(fn, len(args)); err != nil {
+			return err
+		}
+
+		// the body runs in the file of the function, e.g. a module
+		file := e.file
+		e.frames = append(e.frames, object.StackFrame{Function: fn.Name, File: file, Position: pos})
+		e.file = fn.File
+		defer func() { e.frames, e.file = e.frames[:len(e.frames)-1], file }()
+
+		var evaluated object.Object
+		if extendedEnv, err := e.extendFunctionEnv(fn, args); err != nil {
+			evaluated = err
+		} else {
+			evaluated = e.Eval(fn.Body, extendedEnv, ScopeFunction)
+		}
 		switch ev := evaluated.(type) {
 		case *object.Break, *object.Continue:
-			return newError("invalid keyword inside function: %s", ev.Type())
+			err := newError("invalid keyword inside function: %s", ev.Type())
+			err.File, err.Position = e.file, fn.Body.Span().Start
+			evaluated = err
 		}
-		return unwrapReturnValue(evaluated)
+		e.traceError(evaluated)
+		if result := unwrapReturnValue(evaluated); result != nil {
+			return result
+		}
+		return NULL // the body is empty or ends with a statement without value
 	case *object.Builtin:
-		return fn.Fn(args...)
+		// the functions called back by the builtin show its call in tracebacks
+		apply := e.ctx.Apply
+		e.ctx.Apply = func(fn object.Object, args []object.Object) object.Object {
+			return e.applyFunction(fn, args, pos)
+		}
+		defer func() { e.ctx.Apply = apply }()
+
+		return fn.Call(e.ctx, args...)
 	default:
-		return newError("not a function: %s", fn.Type())
+		return object.NewError(object.TYPE_ERROR, "not a function: %s", fn.Type())
+	}
+}
+
+// traceError gives an error raised in the active calls their stack, unless it already has one
+func (e *Evaluator) traceError(obj object.Object) {
+	if err, ok := obj.(*object.Error); ok && err.Stack == nil {
+		err.Stack = make([]object.StackFrame, len(e.frames))
+		copy(err.Stack, e.frames)
+	}
+}
+
+func checkArity(fn *object.Function, numArgs int) *object.Error {
+	required := len(fn.Parameters)
+	for i, d := range fn.Defaults {
+		if d != nil {
+			required = i
+			break
+		}
+	}
+
+	total := len(fn.Parameters)
+	if fn.Rest != nil {
+		total = -1
+	}
+	if numArgs < required || (total >= 0 && numArgs > total) {
+		return object.NewError(object.TYPE_ERROR, WrongNumberOfArguments(required, total, numArgs))
+	}
+	return nil
+}
+
+// WrongNumberOfArguments is the error message of a call to a function
+// taking between required and total arguments, total is negative when there is no maximum
+func WrongNumberOfArguments(required, total, got int) string {
+	if total < 0 {
+		return fmt.Sprintf("wrong number of arguments: want at least %d, got=%d", required, got)
+	}
+	if required == total {
+		return fmt.Sprintf("wrong number of arguments: want=%d, got=%d", total, got)
 	}
+	return fmt.Sprintf("wrong number of arguments: want=%d to %d, got=%d", required, total, got)
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// extendFunctionEnv binds the parameters, missing arguments get their default value
+// evaluated in the new environment, so it can refer to the previous parameters
+func (e *Evaluator) extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+
+		val := e.Eval(fn.Defaults[paramIdx], env, ScopeFunction)
+		if err, ok := val.(*object.Error); ok {
+			return nil, err
+		}
+		env.Set(param.Value, val)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
 	}
 
-	return env
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -270,28 +383,28 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+func (e *Evaluator) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := e.builtins[node.Value]; ok {
 		return builtin
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return object.NewError(object.NAME_ERROR, "identifier not found: %s", node.Value)
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment, scope ScopeType) object.Object {
-	condition := Eval(ie.Condition, env, scope)
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment, scope ScopeType) object.Object {
+	condition := e.Eval(ie.Condition, env, scope)
 	if isError(condition) {
 		return condition
 	}
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env, scope)
+		return e.Eval(ie.Consequence, env, scope)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env, scope)
+		return e.Eval(ie.Alternative, env, scope)
 	} else {
 		return NULL
 	}
@@ -310,11 +423,11 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
-func evalProgram(program *ast.Program, env *object.Environment, scope ScopeType) object.Object {
+func (e *Evaluator) evalProgram(program *ast.Program, env *object.Environment, scope ScopeType) object.Object {
 	var result object.Object
 
 	for _, statement := range program.Statements {
-		result = Eval(statement, env, scope)
+		result = e.Eval(statement, env, scope)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -327,10 +440,10 @@ func evalProgram(program *ast.Program, env *object.Environment, scope ScopeType)
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment, scope ScopeType) object.Object {
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment, scope ScopeType) object.Object {
 	var result object.Object
 	for _, statement := range block.Statements {
-		result = Eval(statement, env, scope)
+		result = e.Eval(statement, env, scope)
 
 		if result != nil {
 			rt := result.Type()
@@ -350,7 +463,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return object.NewError(object.TYPE_ERROR, "unknown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -361,52 +474,168 @@ func evalBangExpression(right object.Object) object.Object {
 	case FALSE, NULL:
 		return TRUE
 	default:
-		if obj, ok := right.(*object.Integer); ok && obj.Value == 0 {
-			return TRUE
+		switch obj := right.(type) {
+		case *object.Integer:
+			return nativeBoolToBooleanObject(obj.Value == 0)
+		case *object.Float:
+			return nativeBoolToBooleanObject(obj.Value == 0)
 		}
 		return FALSE
 	}
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return object.NewInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInteger:
+		return object.NewInteger(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return object.NewError(object.TYPE_ERROR, "unknown operator: -%s", right.Type())
+	}
+}
+
+// evalLogicalExpression returns the operand deciding the result, like JavaScript.
+// The right operand is only evaluated when the left one does not decide it
+func (e *Evaluator) evalLogicalExpression(node *ast.LogicalExpression, env *object.Environment, scope ScopeType) object.Object {
+	left := e.Eval(node.Left, env, scope)
+	if isError(left) {
+		return left
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+
+	switch node.Operator {
+	case "&&":
+		if !isTruthy(left) {
+			return left
+		}
+	case "||":
+		if isTruthy(left) {
+			return left
+		}
+	default:
+		return object.NewError(object.TYPE_ERROR, "unknown operator: %s", node.Operator)
+	}
+	return e.Eval(node.Right, env, scope)
 }
 
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalInfixIntegerExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalInfixFloatExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalInfixStringExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(objectsEqual(left, right, nil))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!objectsEqual(left, right, nil))
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return object.NewError(object.TYPE_ERROR, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return object.NewError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// objectsEqual compares arrays, tuples and hashes by their elements, functions by identity.
+// seen holds the pairs being compared, so arrays containing themselves do not recurse forever
+func objectsEqual(left, right object.Object, seen map[[2]object.Object]bool) bool {
+	if left == right {
+		return true
+	}
+	if isNumber(left) && isNumber(right) {
+		return isTruthy(evalInfixExpression("==", left, right))
+	}
+	if left.Type() != right.Type() {
+		return false
+	}
+
+	pair := [2]object.Object{left, right}
+	if seen[pair] {
+		return true
+	}
+
+	switch left := left.(type) {
+	case *object.String:
+		return left.Value == right.(*object.String).Value
+	case *object.Array:
+		return elementsEqual(left.Elements, right.(*object.Array).Elements, pair, seen)
+	case *object.Tuple:
+		return elementsEqual(left.Elements, right.(*object.Tuple).Elements, pair, seen)
+	case *object.Hash:
+		right := right.(*object.Hash)
+		if len(left.Pairs) != len(right.Pairs) {
+			return false
+		}
+		if seen == nil {
+			seen = map[[2]object.Object]bool{}
+		}
+		seen[pair] = true
+		for key, l := range left.Pairs {
+			r, ok := right.Pairs[key]
+			if !ok || !objectsEqual(l.Value, r.Value, seen) {
+				return false
+			}
+		}
+		return true
 	}
+	return false
 }
 
+func elementsEqual(left, right []object.Object, pair [2]object.Object, seen map[[2]object.Object]bool) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	if seen == nil {
+		seen = map[[2]object.Object]bool{}
+	}
+	seen[pair] = true
+	for i := range left {
+		if !objectsEqual(left[i], right[i], seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// evalInfixIntegerExpression promotes results that overflow int64 to big integers
 func evalInfixIntegerExpression(operator string, left, right object.Object) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+	if !lok || !rok {
+		return evalInfixBigIntegerExpression(operator, toBigInt(left), toBigInt(right))
+	}
+	leftVal, rightVal := l.Value, r.Value
 
 	switch operator {
 	case "+", "+=":
-		return &object.Integer{Value: leftVal + rightVal}
+		if result := leftVal + rightVal; (result > leftVal) == (rightVal > 0) {
+			return &object.Integer{Value: result}
+		}
 	case "-", "-=":
-		return &object.Integer{Value: leftVal - rightVal}
+		if result := leftVal - rightVal; (result < leftVal) == (rightVal > 0) {
+			return &object.Integer{Value: result}
+		}
 	case "*", "*=":
-		return &object.Integer{Value: leftVal * rightVal}
+		if result, ok := multiplyInt64(leftVal, rightVal); ok {
+			return &object.Integer{Value: result}
+		}
 	case "/", "/=":
-		return &object.Integer{Value: leftVal / rightVal}
+		if rightVal == 0 {
+			return object.NewError(object.VALUE_ERROR, "division by zero")
+		}
+		if leftVal != math.MinInt64 || rightVal != -1 {
+			return &object.Integer{Value: leftVal / rightVal}
+		}
 	case "%", "%=":
+		if rightVal == 0 {
+			return object.NewError(object.VALUE_ERROR, "division by zero")
+		}
 		return &object.Integer{Value: leftVal % rightVal}
 
 	case "==":
@@ -422,7 +651,89 @@ func evalInfixIntegerExpression(operator string, left, right object.Object) obje
 	case "<=":
 		return nativeBoolToBooleanObject(leftVal <= rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return object.NewError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+	// the result overflows int64
+	return evalInfixBigIntegerExpression(operator, big.NewInt(leftVal), big.NewInt(rightVal))
+}
+
+func evalInfixBigIntegerExpression(operator string, leftVal, rightVal *big.Int) object.Object {
+	switch operator {
+	case "+", "+=":
+		return object.NewInteger(new(big.Int).Add(leftVal, rightVal))
+	case "-", "-=":
+		return object.NewInteger(new(big.Int).Sub(leftVal, rightVal))
+	case "*", "*=":
+		return object.NewInteger(new(big.Int).Mul(leftVal, rightVal))
+	case "/", "/=":
+		if rightVal.Sign() == 0 {
+			return object.NewError(object.VALUE_ERROR, "division by zero")
+		}
+		return object.NewInteger(new(big.Int).Quo(leftVal, rightVal))
+	case "%", "%=":
+		if rightVal.Sign() == 0 {
+			return object.NewError(object.VALUE_ERROR, "division by zero")
+		}
+		return object.NewInteger(new(big.Int).Rem(leftVal, rightVal))
+
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	default:
+		return object.NewError(object.TYPE_ERROR, "unknown operator: %s %s %s", object.INTEGER_OBJ, operator, object.INTEGER_OBJ)
+	}
+}
+
+// multiplyInt64 returns false when the product overflows int64
+func multiplyInt64(left, right int64) (int64, bool) {
+	result := left * right
+	if left != 0 && (result/left != right || (left == -1 && right == math.MinInt64)) {
+		return 0, false
+	}
+	return result, true
+}
+
+// evalInfixFloatExpression handles floats and integers mixed with floats
+func evalInfixFloatExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+", "+=":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-", "-=":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*", "*=":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/", "/=":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%", "%=":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	default:
+		return object.NewError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -433,25 +744,94 @@ func evalInfixStringExpression(operator string, left, right object.Object) objec
 	switch operator {
 	case "+":
 		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return object.NewError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// interpolate joins parts as they are printed, strings without quotes
+func interpolate(parts []object.Object) *object.String {
+	var out strings.Builder
+	for _, part := range parts {
+		out.WriteString(part.Inspect())
 	}
+	return &object.String{Value: out.String()}
 }
 
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
+	case left.Type() == object.TUPLE_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalTupleIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
-		return newError("index operator not supported: %s", left.Type())
+		return object.NewError(object.TYPE_ERROR, "index operator not supported: %s", left.Type())
+	}
+}
+
+func evalTupleIndexExpression(tuple, index object.Object) object.Object {
+	elements := tuple.(*object.Tuple).Elements
+	idx, ok := object.Int64(index)
+	if !ok {
+		return NULL // a big integer is out of range
+	}
+	if idx < 0 {
+		idx += int64(len(elements))
+	}
+	if idx < 0 || idx >= int64(len(elements)) {
+		return NULL
+	}
+	return elements[idx]
+}
+
+// evalStringIndexExpression returns the character at index, counting characters rather than bytes
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	value := str.(*object.String).Value
+	idx, ok := object.Int64(index)
+	if !ok {
+		return NULL // a big integer is out of range
+	}
+	if idx < 0 {
+		idx += int64(utf8.RuneCountInString(value))
 	}
+	if idx < 0 {
+		return NULL
+	}
+	i := int64(0)
+	for _, ch := range value {
+		if i == idx {
+			return &object.String{Value: string(ch)}
+		}
+		i++
+	}
+	return NULL
 }
 
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
+	idx, ok := object.Int64(index)
+	if !ok {
+		return NULL // a big integer is out of range
+	}
+	if idx < 0 {
+		idx += int64(len(arrayObject.Elements))
+	}
 	max := int64(len(arrayObject.Elements) - 1)
 	if idx < 0 || idx > max {
 		return NULL
@@ -462,12 +842,12 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
+	_, key, ok := object.HashKeyOf(index)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return object.NewError(object.TYPE_ERROR, "unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Pairs[key]
 	if !ok {
 		return NULL
 	}
@@ -475,66 +855,74 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 	return pair.Value
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment, scope ScopeType) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment, scope ScopeType) object.Object {
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env, scope)
+	for _, pair := range node.Pairs {
+		key := e.Eval(pair.Key, env, scope)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		frozen, hashKey, ok := object.HashKeyOf(key)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return object.NewError(object.TYPE_ERROR, "unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env, scope)
+		value := e.Eval(pair.Value, env, scope)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, object.HashPair{Key: frozen, Value: value})
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
-func evalAssignExpression(node *ast.AssignExpression, env *object.Environment, scope ScopeType) object.Object {
+func (e *Evaluator) evalAssignExpression(node *ast.AssignExpression, env *object.Environment, scope ScopeType) object.Object {
 	switch exp := node.Left.(type) {
 	case *ast.Identifier:
-		return evalIdentifierAssignExpression(exp, node.Operator, node.Value, env, scope)
+		return e.evalIdentifierAssignExpression(exp, node.Operator, node.Value, env, scope)
 	case *ast.IndexExpression:
-		return evalIndexAssignExpression(exp, node.Operator, node.Value, env, scope)
+		return e.evalIndexAssignExpression(exp, node.Operator, node.Value, env, scope)
+	case *ast.SliceExpression:
+		return e.evalSliceAssignExpression(exp, node.Operator, node.Value, env, scope)
 	default:
 		return newError("invalid identifier when assign value: %s", node.Left.String())
 	}
 }
 
-func evalIdentifierAssignExpression(ident *ast.Identifier, operator string, value ast.Expression, env *object.Environment, scope ScopeType) object.Object {
-	val := Eval(value, env, scope)
+func (e *Evaluator) evalIdentifierAssignExpression(ident *ast.Identifier, operator string, value ast.Expression, env *object.Environment, scope ScopeType) object.Object {
+	val := e.Eval(value, env, scope)
 	if isError(val) {
 		return val
 	}
 
 	cur, ok := env.Get(ident.Value)
 	if !ok {
-		return newError("identifier not found: %s", ident.Value)
+		return object.NewError(object.NAME_ERROR, "identifier not found: %s", ident.Value)
 	}
 
 	if isCompoundAssignmentOperator(operator) {
-		if !(cur.Type() == object.INTEGER_OBJ && val.Type() == object.INTEGER_OBJ) {
-			return newError("unsupported assign %s %s %s", cur.Type(), operator, val.Type())
+		val = evalCompoundAssign(operator, cur, val)
+		if isError(val) {
+			return val
 		}
-		val = evalInfixExpression(operator, cur, val)
 	}
 
 	env.Assign(ident.Value, val)
 	return val
 }
 
-func evalIndexAssignExpression(exp *ast.IndexExpression, operator string, value ast.Expression, env *object.Environment, scope ScopeType) object.Object {
+func evalCompoundAssign(operator string, cur, val object.Object) object.Object {
+	if !(isNumber(cur) && isNumber(val)) {
+		return object.NewError(object.TYPE_ERROR, "unsupported assign %s %s %s", cur.Type(), operator, val.Type())
+	}
+	return evalInfixExpression(operator, cur, val)
+}
+
+func (e *Evaluator) evalIndexAssignExpression(exp *ast.IndexExpression, operator string, value ast.Expression, env *object.Environment, scope ScopeType) object.Object {
 	ident, ok := exp.Left.(*ast.Identifier)
 	if !ok {
 		return newError("invalid identifier using index")
@@ -542,123 +930,128 @@ func evalIndexAssignExpression(exp *ast.IndexExpression, operator string, value
 
 	cur, ok := env.Get(ident.Value)
 	if !ok {
-		return newError("identifier not found: %s", ident.Value)
+		return object.NewError(object.NAME_ERROR, "identifier not found: %s", ident.Value)
 	}
 
-	index := Eval(exp.Index, env, scope)
+	index := e.Eval(exp.Index, env, scope)
 	if isError(index) {
 		return index
 	}
 
-	val := Eval(value, env, scope)
+	val := e.Eval(value, env, scope)
 	if isError(val) {
 		return val
 	}
 
+	return evalIndexAssign(ident.Value, cur, index, operator, val)
+}
+
+// evalIndexAssign assigns val to name[index], the collection is modified in place
+func evalIndexAssign(name string, cur, index object.Object, operator string, val object.Object) object.Object {
 	switch {
 	case cur.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
-		return evalArrayIndexAssignExpression(ident, cur, index, operator, val, env)
+		return evalArrayIndexAssign(cur, index, operator, val)
 	case cur.Type() == object.HASH_OBJ:
-		return evalHashIndexAssignExpression(ident, cur, index, operator, val, env)
+		return evalHashIndexAssign(name, cur, index, operator, val)
 	default:
-		return newError("index not supported: %s[%s]", cur.Type(), index.Type())
+		return object.NewError(object.TYPE_ERROR, "index not supported: %s[%s]", cur.Type(), index.Type())
 	}
 }
 
-func evalArrayIndexAssignExpression(
-	ident *ast.Identifier,
-	arr, index object.Object,
-	operator string,
-	val object.Object,
-	env *object.Environment,
-) object.Object {
+func evalArrayIndexAssign(arr, index object.Object, operator string, val object.Object) object.Object {
 	arrayObject := arr.(*object.Array)
-	indexObject := index.(*object.Integer)
 
 	n := len(arrayObject.Elements)
 	if n == 0 {
-		return newError("array is empty. cannot set at any index")
+		return object.NewError(object.INDEX_ERROR, "array is empty. cannot set at any index")
+	}
+	idx, ok := object.Int64(index)
+	if !ok || idx < -int64(n) || idx >= int64(n) {
+		return object.NewError(object.INDEX_ERROR, "valid index range is 0 until %d. got=%s", n-1, index.Inspect())
 	}
-	i := int(indexObject.Value)
-	if i < 0 || i >= n {
-		return newError("valid index range is 0 until %d. got=%d", n-1, i)
+	i := int(idx)
+	if i < 0 {
+		i += n
 	}
 
 	cur := arrayObject.Elements[i]
 	if isCompoundAssignmentOperator(operator) {
-		if !(cur.Type() == object.INTEGER_OBJ && val.Type() == object.INTEGER_OBJ) {
-			return newError("unsupported assign %s[%s] -> %s %s %s", arr.Type(), index.Type(), cur.Type(), operator, val.Type())
+		if !(isNumber(cur) && isNumber(val)) {
+			return object.NewError(object.TYPE_ERROR, "unsupported assign %s[%s] -> %s %s %s", arr.Type(), index.Type(), cur.Type(), operator, val.Type())
 		}
 		val = evalInfixExpression(operator, cur, val)
 	}
 
 	arrayObject.Elements[i] = val
-	env.Assign(ident.Value, arrayObject)
 	return val
 }
 
-func evalHashIndexAssignExpression(
-	ident *ast.Identifier,
-	hash, index object.Object,
-	operator string, val object.Object,
-	env *object.Environment,
-) object.Object {
+func evalHashIndexAssign(name string, hash, index object.Object, operator string, val object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	hashableKey, ok := index.(object.Hashable)
+	frozen, key, ok := object.HashKeyOf(index)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return object.NewError(object.TYPE_ERROR, "unusable as hash key: %s", index.Type())
 	}
-	key := hashableKey.HashKey()
 
 	if isCompoundAssignmentOperator(operator) {
 		cur, ok := hashObject.Pairs[key]
 		if !ok {
-			return newError("cannot assign key not exist: %s[%s] %s %s", ident.Value, index.Inspect(), operator, val.Inspect())
+			return object.NewError(object.INDEX_ERROR, "cannot assign key not exist: %s[%s] %s %s", name, index.Inspect(), operator, val.Inspect())
 		}
-		if !(cur.Value.Type() == object.INTEGER_OBJ && val.Type() == object.INTEGER_OBJ) {
-			return newError("unsupported assign %s[%s] -> %s %s %s", hashObject.Type(), cur.Key.Type(), cur.Value.Type(), operator, val.Type())
+		if !(isNumber(cur.Value) && isNumber(val)) {
+			return object.NewError(object.TYPE_ERROR, "unsupported assign %s[%s] -> %s %s %s", hashObject.Type(), cur.Key.Type(), cur.Value.Type(), operator, val.Type())
 		}
 		val = evalInfixExpression(operator, cur.Value, val)
 	}
 
-	hashObject.Pairs[key] = object.HashPair{
-		Key:   index,
+	hashObject.Set(key, object.HashPair{
+		Key:   frozen,
 		Value: val,
-	}
-	env.Assign(ident.Value, hashObject)
+	})
 	return val
 }
 
-func evalWhileStatement(node *ast.WhileStatement, env *object.Environment, scope ScopeType) object.Object {
+func (e *Evaluator) evalSliceAssignExpression(exp *ast.SliceExpression, operator string, value ast.Expression, env *object.Environment, scope ScopeType) object.Object {
+	ident, ok := exp.Left.(*ast.Identifier)
+	if !ok {
+		return newError("invalid identifier using slice")
+	}
+
+	cur, ok := env.Get(ident.Value)
+	if !ok {
+		return object.NewError(object.NAME_ERROR, "identifier not found: %s", ident.Value)
+	}
+
+	bounds := e.evalSliceBounds(exp, env, scope)
+	if len(bounds) == 1 && isError(bounds[0]) {
+		return bounds[0]
+	}
+
+	val := e.Eval(value, env, scope)
+	if isError(val) {
+		return val
+	}
+
+	return evalSliceAssign(cur, bounds[0], bounds[1], bounds[2], operator, val)
+}
+
+func (e *Evaluator) evalWhileStatement(node *ast.WhileStatement, env *object.Environment, scope ScopeType) object.Object {
 
 	env = object.NewEnclosedEnvironment(env)
 
-	condition := Eval(node.Condition, env, scope)
+	condition := e.Eval(node.Condition, env, scope)
 	if isError(condition) {
 		return condition
 	}
 	for isTruthy(condition) {
 
-		stmt := evalBlockStatement(node.Body, env, ScopeLoop)
-		if stmt != nil {
-			switch stmt.Type() {
-			case object.BREAK_OBJ:
-				return NULL
-			case object.CONTINUE_OBJ:
-				// Do Nothing, statements already stopped inside evalBlockStatement
-			case object.RETURN_VALUE_OBJ:
-				if scope == ScopeFunction {
-					return stmt
-				} else {
-					return newError("return statement unsupported if while-loop not inside a function")
-				}
-			case object.ERROR_OBJ:
-				return stmt
-			}
+		stmt := e.evalBlockStatement(node.Body, env, loopScope(scope))
+		if result, stop := loopControl(stmt, scope); stop {
+			return result
 		}
 
-		condition = Eval(node.Condition, env, scope)
+		condition = e.Eval(node.Condition, env, scope)
 		if isError(condition) {
 			return condition
 		}
@@ -666,6 +1059,282 @@ func evalWhileStatement(node *ast.WhileStatement, env *object.Environment, scope
 	return NULL
 }
 
+func (e *Evaluator) evalForStatement(node *ast.ForStatement, env *object.Environment, scope ScopeType) object.Object {
+
+	env = object.NewEnclosedEnvironment(env)
+
+	if node.Init != nil {
+		init := e.Eval(node.Init, env, scope)
+		if isError(init) {
+			return init
+		}
+	}
+
+	for {
+		if node.Condition != nil {
+			condition := e.Eval(node.Condition, env, scope)
+			if isError(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				return NULL
+			}
+		}
+
+		stmt := e.evalBlockStatement(node.Body, env, loopScope(scope))
+		if result, stop := loopControl(stmt, scope); stop {
+			return result
+		}
+
+		if node.Update != nil {
+			update := e.Eval(node.Update, env, scope)
+			if isError(update) {
+				return update
+			}
+		}
+	}
+}
+
+func (e *Evaluator) evalForInStatement(node *ast.ForInStatement, env *object.Environment, scope ScopeType) object.Object {
+	iterable := e.Eval(node.Iterable, env, scope)
+	if isError(iterable) {
+		return iterable
+	}
+	iter := newIterator(iterable)
+	if isError(iter) {
+		return iter
+	}
+	it := iter.(*object.Iterator)
+
+	env = object.NewEnclosedEnvironment(env)
+
+	for {
+		key, value, ok := it.Next()
+		if !ok {
+			return NULL
+		}
+
+		if node.Key != nil {
+			env.Set(node.Key.Value, key)
+		} else if it.Keys {
+			value = key
+		}
+		env.Set(node.Value.Value, value)
+
+		stmt := e.evalBlockStatement(node.Body, env, loopScope(scope))
+		if result, stop := loopControl(stmt, scope); stop {
+			return result
+		}
+	}
+}
+
+// importModule evaluates a module in its own environment the first time it is imported,
+// the module runs in a frame called at pos
+func (e *Evaluator) importModule(name string, pos token.Position) object.Object {
+	return e.modules.Import(name, e.file, func(path string, program *ast.Program) object.Object {
+		file, exports := e.file, e.exports
+		e.frames = append(e.frames, object.StackFrame{Function: object.ModuleFunction, File: file, Position: pos})
+		e.file, e.exports = path, nil
+		defer func() { e.frames, e.file, e.exports = e.frames[:len(e.frames)-1], file, exports }()
+
+		env := object.NewEnvironment()
+		result := e.Eval(program, env, ScopeNone)
+		if isError(result) {
+			e.traceError(result)
+			return result
+		}
+
+		values := map[string]object.Object{}
+		for _, name := range e.exports {
+			values[name], _ = env.Get(name)
+		}
+		return Namespace(e.exports, values, result)
+	})
+}
+
+func (e *Evaluator) evalTryStatement(node *ast.TryStatement, env *object.Environment, scope ScopeType) object.Object {
+	result := e.evalBlockStatement(node.Block, object.NewEnclosedEnvironment(env), scope)
+
+	if err, ok := result.(*object.Error); ok {
+		if !err.Catchable() {
+			return err
+		}
+		if node.Catch != nil {
+			if err.Stack == nil {
+				err.Stack = make([]object.StackFrame, len(e.frames))
+				copy(err.Stack, e.frames)
+			}
+			catchEnv := object.NewEnclosedEnvironment(env)
+			if node.CatchParam != nil {
+				catchEnv.Set(node.CatchParam.Value, caughtError(err))
+			}
+			result = e.evalBlockStatement(node.Catch, catchEnv, scope)
+		}
+	}
+
+	// finally replaces the result only when it leaves the statement itself
+	if node.Finally != nil {
+		final := e.evalBlockStatement(node.Finally, object.NewEnclosedEnvironment(env), scope)
+		if isControlFlow(final) {
+			return final
+		}
+	}
+
+	if isControlFlow(result) {
+		return result
+	}
+	return NULL
+}
+
+// isControlFlow tells whether obj ends the statements around it, e.g. a return value or an error
+func isControlFlow(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.Type() {
+	case object.ERROR_OBJ, object.RETURN_VALUE_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+		return true
+	}
+	return false
+}
+
+// throwError raises val, a string is the message and a hash may set the "message" and "type"
+func throwError(val object.Object) *object.Error {
+	switch val := val.(type) {
+	case *object.String:
+		return object.NewError(object.GENERIC_ERROR, "%s", val.Value)
+	case *object.Hash:
+		message, ok := hashField(val, "message")
+		if !ok {
+			return object.NewError(object.GENERIC_ERROR, "%s", val.Inspect())
+		}
+		err := object.NewError(object.GENERIC_ERROR, "%s", stringValue(message))
+		if kind, ok := hashField(val, "type"); ok {
+			err.Kind = object.ErrorKind(stringValue(kind))
+		}
+		// a caught error thrown again is still raised where it first was
+		if val.Caught != nil {
+			err.File, err.Position, err.Stack = val.Caught.File, val.Caught.Position, val.Caught.Stack
+		}
+		return err
+	default:
+		return object.NewError(object.GENERIC_ERROR, "%s", val.Inspect())
+	}
+}
+
+// caughtError is the hash bound by a catch block, with the message, type and stack trace of err
+func caughtError(err *object.Error) *object.Hash {
+	stack := []object.Object{}
+	for _, line := range err.StackTrace() {
+		stack = append(stack, &object.String{Value: line})
+	}
+
+	hash := object.NewHash()
+	for _, pair := range []object.HashPair{
+		{Key: &object.String{Value: "message"}, Value: &object.String{Value: err.Message}},
+		{Key: &object.String{Value: "type"}, Value: &object.String{Value: err.KindName()}},
+		{Key: &object.String{Value: "stack"}, Value: &object.Array{Elements: stack}},
+	} {
+		hash.Set(pair.Key.(*object.String).HashKey(), pair)
+	}
+	hash.Caught = err
+	return hash
+}
+
+func hashField(hash *object.Hash, name string) (object.Object, bool) {
+	pair, ok := hash.Pairs[(&object.String{Value: name}).HashKey()]
+	return pair.Value, ok
+}
+
+func stringValue(obj object.Object) string {
+	if str, ok := obj.(*object.String); ok {
+		return str.Value
+	}
+	return obj.Inspect()
+}
+
+// loopScope returns the scope of the body of a loop evaluated in scope
+func loopScope(scope ScopeType) ScopeType {
+	if scope == ScopeFunction || scope == ScopeFunctionLoop {
+		return ScopeFunctionLoop
+	}
+	return ScopeLoop
+}
+
+// loopControl handles the result of a loop body, stop is true when the loop must end with result
+func loopControl(stmt object.Object, scope ScopeType) (result object.Object, stop bool) {
+	if stmt == nil {
+		return nil, false
+	}
+
+	switch stmt.Type() {
+	case object.BREAK_OBJ:
+		return NULL, true
+	case object.CONTINUE_OBJ:
+		// Do Nothing, statements already stopped inside evalBlockStatement
+	case object.RETURN_VALUE_OBJ:
+		if scope == ScopeFunction || scope == ScopeFunctionLoop {
+			return stmt, true
+		} else {
+			return newError("return statement unsupported if while-loop not inside a function"), true
+		}
+	case object.ERROR_OBJ:
+		return stmt, true
+	}
+	return nil, false
+}
+
+// newIterator returns an *object.Iterator over the elements of an array, the characters of a string
+// or the pairs of a hash in insertion order
+func newIterator(obj object.Object) object.Object {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return elementsIterator(obj.Elements)
+	case *object.Tuple:
+		return elementsIterator(obj.Elements)
+
+	case *object.String:
+		chars := []rune(obj.Value)
+		i := 0
+		next := func() (object.Object, object.Object, bool) {
+			if i >= len(chars) {
+				return nil, nil, false
+			}
+			i++
+			return &object.Integer{Value: int64(i - 1)}, &object.String{Value: string(chars[i-1])}, true
+		}
+		return &object.Iterator{Next: next}
+
+	case *object.Hash:
+		pairs := obj.Ordered()
+		i := 0
+		next := func() (object.Object, object.Object, bool) {
+			if i >= len(pairs) {
+				return nil, nil, false
+			}
+			i++
+			return pairs[i-1].Key, pairs[i-1].Value, true
+		}
+		return &object.Iterator{Next: next, Keys: true}
+
+	default:
+		return object.NewError(object.TYPE_ERROR, "not iterable: %s", obj.Type())
+	}
+}
+
+// elementsIterator iterates the indexes and the values of elements
+func elementsIterator(elements []object.Object) *object.Iterator {
+	i := 0
+	next := func() (object.Object, object.Object, bool) {
+		if i >= len(elements) {
+			return nil, nil, false
+		}
+		i++
+		return &object.Integer{Value: int64(i - 1)}, elements[i-1], true
+	}
+	return &object.Iterator{Next: next}
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
@@ -684,6 +1353,37 @@ func isError(obj object.Object) bool {
 	return false
 }
 
+func isNumber(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.BigInteger, *object.Float:
+		return true
+	}
+	return false
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInteger:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	case *object.Float:
+		return obj.Value
+	}
+	return 0
+}
+
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInteger:
+		return obj.Value
+	}
+	return new(big.Int)
+}
+
 func isCompoundAssignmentOperator(operator string) bool {
 	switch operator {
 	case "+=", "-=", "/=", "*=", "%=":
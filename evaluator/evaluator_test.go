@@ -1,6 +1,8 @@
 package evaluator
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/labasubagia/interpreter/lexer"
@@ -8,599 +10,6 @@ import (
 	"github.com/labasubagia/interpreter/parser"
 )
 
-func TestEvalIntegerExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"5", 5},
-		{"10", 10},
-
-		{"-5", -5},
-		{"-10", -10},
-
-		{"5 + 5 + 5 + 5 - 10", 10},
-		{"2 * 2 * 2 * 2 * 2", 32},
-		{"-50 + 100 + -50", 0},
-		{"5 * 2 + 10", 20},
-		{"5 + 2 * 10", 25},
-		{"20 + 2 * -10", 0},
-		{"50 / 2 * 2 + 10", 60},
-		{"2 * (5 + 10)", 30},
-		{"3 * 3 * 3 + 10", 37},
-		{"3 * (3 * 3) + 10", 37},
-		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
-		{"10 % 5", 0},
-		{"3 % 2", 1},
-		{"10 * 5 % 20", 10},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
-	}
-}
-
-func TestEvalBooleanObject(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected bool
-	}{
-		{"true", true},
-		{"false", false},
-
-		{"1 < 2", true},
-		{"1 > 2", false},
-		{"1 < 1", false},
-		{"1 > 1", false},
-		{"1 >= 1", true},
-		{"2 >= 1", true},
-		{"1 >= 2", false},
-		{"1 <= 1", true},
-		{"1 <= 2", true},
-		{"2 <= 1", false},
-		{"1 == 1", true},
-		{"1 != 1", false},
-		{"1 == 2", false},
-		{"1 != 2", true},
-
-		{"true == true", true},
-		{"false == false", true},
-		{"true == false", false},
-		{"true != false", true},
-		{"false != true", true},
-		{"(1 < 2) == true", true},
-		{"(1 < 2) == false", false},
-		{"(1 > 2) == true", false},
-		{"(1 > 2) == false", true},
-
-		{"null == null", true},
-		{"null != null", false},
-		{"let x = null; x == null", true},
-		{"let x = 12; x != null", true},
-		{"let x = false; x != null", true},
-		{"let x = true; x != null", true},
-		{"let x = 0; x != null", true},
-		{"let arr = [1,2,3]; arr[20] == null", true},
-		{"let arr = [1,2,3]; arr[2] != null", true},
-		{`let hash = {}; hash["a"] == null`, true},
-		{`let hash = {"a": 12}; hash["a"] == null`, false},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testBooleanObject(t, evaluated, tt.expected)
-	}
-}
-
-func TestBangOperator(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected bool
-	}{
-		{"!true", false},
-		{"!false", true},
-		{"!5", false},
-		{"!!true", true},
-		{"!!false", false},
-		{"!!5", true},
-		{"!0", true},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testBooleanObject(t, evaluated, tt.expected)
-	}
-}
-
-func TestIfElseExpressions(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected any
-	}{
-		{"if (true) { 10 }", 10},
-		{"if (false) { 10 }", nil},
-		{"if (1) { 10 }", 10},
-		{"if (1 < 2) { 10 }", 10},
-		{"if (1 > 2) { 10 }", nil},
-		{"if (1 > 2) { 10 } else { 20 }", 20},
-		{"if (1 < 2) { 10 } else { 20 }", 10},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
-		if ok {
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			testNullObject(t, evaluated)
-		}
-	}
-}
-
-func TestReturnStatements(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"return 10;", 10},
-		{"return 10; 9;", 10},
-		{"return 2 * 5; 9;", 10},
-		{"9; return 2 * 5; 9;", 10},
-		{
-			`
-			if (10 > 1) {
-				if (10 > 1) {
-					return 10;
-				}
-				return 1;
-			}
-			`,
-			10,
-		},
-	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
-	}
-}
-
-func TestErrorHandling(t *testing.T) {
-	tests := []struct {
-		input           string
-		expectedMessage string
-	}{
-		{
-			"5 + true;",
-			"type mismatch: INTEGER + BOOLEAN",
-		},
-		{
-			"5 + true; 5;",
-			"type mismatch: INTEGER + BOOLEAN",
-		},
-		{
-			"-true",
-			"unknown operator: -BOOLEAN",
-		},
-		{
-			"true + false;",
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
-		{
-			"5; true + false; 5",
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
-		{
-			"if (10 > 1) { true + false; }",
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
-		{
-			`
-			if (10 > 1) {
-				if (10 > 1) {
-					return true + false;
-				}
-				return 1;
-			}
-			`,
-			"unknown operator: BOOLEAN + BOOLEAN",
-		},
-		{
-			"foobar",
-			"identifier not found: foobar",
-		},
-		{
-			`"Hello" - "World"`,
-			"unknown operator: STRING - STRING",
-		},
-
-		{
-			`[1,2,3]["key"];`,
-			"index operator not supported: ARRAY",
-		},
-		{
-			`{"name": "Monkey"}[fn(x) { x }];`,
-			"unusable as hash key: FUNCTION",
-		},
-
-		// assign
-		{
-			"12 = 12;",
-			"invalid identifier when assign value: 12",
-		},
-		{
-			"[1,2][0] = 12;",
-			"invalid identifier using index",
-		},
-		{
-			`{"a": 12, "b": 3}["a"] = 8;`,
-			"invalid identifier using index",
-		},
-		{
-			"foobar = 12;",
-			"identifier not found: foobar",
-		},
-		{
-			"foo = bar;",
-			"identifier not found: bar",
-		},
-		{
-			"arr[0] = 12",
-			"identifier not found: arr",
-		},
-		{
-			"let arr = [1,2]; arr[x] = 12",
-			"identifier not found: x",
-		},
-		{
-			"let arr = [1,2]; arr[0] = y",
-			"identifier not found: y",
-		},
-		{
-			`let arr = [1,2]; arr[false] = 12`,
-			"index not supported: ARRAY[BOOLEAN]",
-		},
-		{
-			`let arr = [1,2]; arr["invalid"] = 12`,
-			"index not supported: ARRAY[STRING]",
-		},
-		{
-			`let arr = []; arr[0] = 12`,
-			"array is empty. cannot set at any index",
-		},
-		{
-			`let arr = [1,2]; arr[4] = 12`,
-			"valid index range is 0 until 1. got=4",
-		},
-		{
-			`let hash = {"a": 12}; hash[[1,2]] = 12;`,
-			"unusable as hash key: ARRAY",
-		},
-
-		{
-			`let x = 12; x += "abc";`,
-			"unsupported assign INTEGER += STRING",
-		},
-		{
-			`
-				let arr = [false, 12];
-				arr[1] += "eat";
-				arr[1];
-			`,
-			"unsupported assign ARRAY[INTEGER] -> INTEGER += STRING",
-		},
-		{
-			`
-				let hash = {};
-				hash["a"] += 12;
-				hash["a"];
-			`,
-			"cannot assign key not exist: hash[a] += 12",
-		},
-
-		{
-			`
-				let hash = {"a": 12};
-				hash["a"] += "eat";
-				hash["a"];
-			`,
-			"unsupported assign HASH[STRING] -> INTEGER += STRING",
-		},
-
-		{
-			`
-				let x = 0;
-				while (true) {
-					x = x + 1;
-					if (x > 4) {
-						return null;
-					}
-				}
-				x;
-			`,
-			"return statement unsupported if while-loop not inside a function",
-		},
-		{
-			"while(x) {x += 1};",
-			"identifier not found: x",
-		},
-
-		{
-			`
-				fn() {
-					x;
-				}()
-			`,
-			"identifier not found: x",
-		},
-		{
-			`
-				fn() {
-					break;
-				}()
-			`,
-			"invalid keyword inside function: BREAK",
-		},
-		{
-			`
-				fn() {
-					continue;
-				}()
-			`,
-			"invalid keyword inside function: CONTINUE",
-		},
-
-		{
-			`	let i = 0;
-				while (i < 5) {
-					fn() {
-						continue;
-					}()
-					i += 1;
-				}
-			`,
-			"invalid keyword inside function: CONTINUE",
-		},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-
-		errObj, ok := evaluated.(*object.Error)
-		if !ok {
-			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
-			continue
-		}
-
-		if errObj.Message != tt.expectedMessage {
-			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
-		}
-	}
-}
-
-func TestLetStatements(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"let a = 5; a;", 5},
-		{"let a = 5 * 5; a;", 25},
-		{"let a = 5; let b = a; b;", 5},
-		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
-	}
-	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
-	}
-}
-
-func TestAssignExpressions(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"let a = 5; a = 12;", 12},
-		{"let a = 5; a = 12; a;", 12},
-		{"let a = 5; a = 12; a = 13;  a;", 13},
-		{"let a = 5; a = 12; a = 5 * 5 + 5;  a;", 30},
-		{
-			`
-				let x = 12;
-				let f = fn() {
-					x = 50
-				}
-				f()
-				x
-			`,
-			50,
-		},
-		{
-			`
-				let x = 12;
-				let f = fn() {
-					let x = 50
-				}
-				f()
-				x
-			`,
-			12,
-		},
-		{
-			`
-				let x = 12;
-				let f = fn() {
-					x = 50
-					let fa = fn() {
-						x = 55
-					}
-					fa()
-				}
-				f()
-				x
-			`,
-			55,
-		},
-		{
-			`
-				let x = 12;
-				let f = fn() {
-					let x = 50
-					let fa = fn() {
-						let x = 55
-					}
-					fa()
-				}
-				f()
-				x
-			`,
-			12,
-		},
-
-		{"let arr = [1,2,3,4]; arr[1+1] = 12; arr[2]", 12},
-		{`let hash = {"a": 12, "b": 4}; hash["a"] = 40; hash["a"];`, 40},
-		{`let hash = {}; hash["z"] = 22; hash["z"];`, 22},
-		{
-			`
-				let arr = [4, 2, 1, 5];
-
-				let fa = fn() {
-					arr[2] = 44;
-				}
-
-				fa();
-				arr[2];
-			`,
-			44,
-		},
-		{
-			`
-				let arr = [4, 2, 1, 5];
-
-				let fa = fn() {
-					let arr = [1,2,3]
-					arr[2] = 44;
-				}
-
-				fa();
-				arr[2];
-			`,
-			1,
-		},
-		{
-			`
-				let arr = [4, 2, 1, 5];
-
-				fn() {
-					arr[2] = 44;
-					fn(){
-						arr[2] = 66;
-					}()
-				}()
-
-				arr[2];
-			`,
-			66,
-		},
-		{
-			`
-				let hash = {};
-
-				fn() {
-					hash["a"] = 66;
-				}()
-
-				hash["a"];
-			`,
-			66,
-		},
-		{
-			`
-				let hash = {};
-
-				fn() {
-					hash["a"] = 66;
-					fn(){
-						hash["a"] = 99;
-					}()
-				}()
-
-				hash["a"];
-			`,
-			99,
-		},
-		{
-			`
-				let hash = {"a": 10};
-
-				fn() {
-					let hash = {}
-					fn(){
-						hash["a"] = 99;
-					}()
-				}()
-
-				hash["a"];
-			`,
-			10,
-		},
-
-		{"let x = 5; x += 10 * 2; x", 25},
-		{"let x = 5; x -= 10; x", -5},
-		{"let x = 5; x *= 10; x", 50},
-		{"let x = 20; x /= 10; x", 2},
-		{"let x = 20; x /= 10 / 2; x", 4},
-		{"let x = 20; x %= 10 / 2; x", 0},
-
-		{
-			`
-			let arr = [1,5,3,4];
-			arr[2] += 20
-			`,
-			23,
-		},
-		{
-			`
-			let arr = [1,5,3,4];
-			arr[2] -= 20
-			`,
-			-17,
-		},
-		{
-			`
-			let arr = [1,5,3,4];
-			arr[2] %= 2
-			`,
-			1,
-		},
-		{
-			`
-				let hash = {"a": 2};
-				hash["a"] *= 12;
-				hash["a"];
-			`,
-			24,
-		},
-		{
-			`
-				let hash = {"a": 2};
-				hash["a"] /= 2;
-				hash["a"];
-			`,
-			1,
-		},
-		{
-			`
-				let hash = {"a": 2};
-				hash["a"] %= 2;
-				hash["a"];
-			`,
-			0,
-		},
-	}
-	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
-	}
-}
-
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2 }"
 
@@ -624,462 +33,23 @@ func TestFunctionObject(t *testing.T) {
 	}
 }
 
-func TestFunctionApplication(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"let identity = fn(x) { x; }; identity(5);", 5},
-		{"let identity = fn(x) { return x; }; identity(5);", 5},
-		{"let double = fn(x) { x * 2; }; double(5);", 10},
-		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
-		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
-		{"fn(x) { x; }(5)", 5},
-	}
-
-	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
-	}
-}
-
-func TestClosures(t *testing.T) {
-	input := `
-		let newAdder = fn(x) {
-			fn(y) { x + y };
-		};
-		let addTwo = newAdder(2);
-		addTwo(2);
-	`
-	testIntegerObject(t, testEval(input), 4)
-}
-
-func TestStringLiteral(t *testing.T) {
-	input := `"Hello World!"`
-
-	evaluated := testEval(input)
-	str, ok := evaluated.(*object.String)
-	if !ok {
-		t.Fatalf("evaluated is not String. got=%T (%+v)", evaluated, evaluated)
-	}
-
-	if str.Value != "Hello World!" {
-		t.Errorf("String has wrong value. got=%q", str.Value)
-	}
-}
-
-func TestStringConcatenation(t *testing.T) {
-	input := `"Hello" + " " + "World!"`
-	evaluated := testEval(input)
-	str, ok := evaluated.(*object.String)
-	if !ok {
-		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
-	}
-	if str.Value != "Hello World!" {
-		t.Errorf("String has wrong value. got=%q", str.Value)
-	}
-}
-
-func TestBuiltinFunctions(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected any
-	}{
-		{`len("")`, 0},
-		{`len("four")`, 4},
-		{`len("hello world")`, 11},
-		{`len([])`, 0},
-		{`len([1, 2, 3])`, 3},
-		{`len({"a": 1, "b": 2, "c": false})`, 3},
-		{`len(1)`, "argument to `len` not supported, got INTEGER"},
-		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
-
-		{`first([])`, nil},
-		{`first([1])`, 1},
-		{`first([2,1,3])`, 2},
-		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
-		{`first([1], [2])`, "wrong number of arguments. got=2, want=1"},
-
-		{`last([])`, nil},
-		{`last([1])`, 1},
-		{`last([2,1,3])`, 3},
-		{`last(1)`, "argument to `last` must be ARRAY, got INTEGER"},
-		{`last([1], [2])`, "wrong number of arguments. got=2, want=1"},
-
-		{`rest([])`, []int64{}},
-		{`rest([1])`, []int64{}},
-		{`rest([1,2])`, []int64{2}},
-		{`let a = [1,2,3]; rest(rest(rest(a)));`, []int64{}},
-		{`let a = []; rest(rest(rest(rest(rest(a)))));`, []int64{}},
-		{`rest(1)`, "argument to `rest` must be ARRAY, got INTEGER"},
-		{`rest([1], [2])`, "wrong number of arguments. got=2, want=1"},
-
-		{`push([], 1)`, []int64{1}},
-		{`push([1], 2)`, []int64{1, 2}},
-		{`push([1,2], 3)`, []int64{1, 2, 3}},
-		{`push(1, 2)`, "argument to `push` must be ARRAY, got INTEGER"},
-		{`push([])`, "wrong number of arguments. got=1, want=2"},
-		{`push([1], 2, 3)`, "wrong number of arguments. got=3, want=2"},
-
-		{
-			`
-			let map = fn(arr, f) {
-				let iter = fn(arr, accumulated) {
-					if (len(arr) == 0) {
-						accumulated
-					} else {
-						iter(rest(arr), push(accumulated, f(first(arr))));
-					}
-				};
-				iter(arr, []);
-			};
-			let a = [1, 2, 3, 4];
-			let double = fn(x) { x * 2 };
-			map(a, double);
-			`,
-			[]int64{2, 4, 6, 8},
-		},
-		{
-			`
-			let reduce = fn(arr, initial, f) {
-				let iter = fn(arr, result) {
-					if (len(arr) == 0) {
-						result;
-					} else {
-						iter(rest(arr), f(result, first(arr)));
-					}
-				};
-				iter(arr, initial);
-			};
-			let sum = fn(arr) {
-				reduce(arr, 0, fn(initial, el) { initial + el });
-			};
-			sum([1, 2, 3, 4, 5]);
-			`,
-			15,
-		},
-
-		{`puts("Hello", "World")`, nil},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-
-		switch expected := tt.expected.(type) {
-		case int:
-			testIntegerObject(t, evaluated, int64(expected))
-		case string:
-			errObj, ok := evaluated.(*object.Error)
-			if !ok {
-				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
-				continue
-			}
-			if errObj.Message != expected {
-				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
-			}
-		case []int64:
-			array, ok := evaluated.(*object.Array)
-			if !ok {
-				t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
-				continue
-			}
-			if len(array.Elements) != len(expected) {
-				t.Errorf("wrong length want %d. got=%d", len(expected), len(array.Elements))
-				continue
-			}
-			for i, e := range array.Elements {
-				if v, ok := e.(*object.Integer); ok && v.Value != expected[i] {
-					t.Errorf("array want %v, but got=%v", array.Inspect(), expected)
-					break
-				}
-			}
-		case nil:
-			if evaluated != NULL {
-				t.Errorf("expected=%v, got=%v", nil, evaluated)
-			}
-		}
-	}
-}
-
-func TestArrayLiterals(t *testing.T) {
-	input := "[1, 2 * 2, 3 + 3];"
-
-	evaluated := testEval(input)
-	result, ok := evaluated.(*object.Array)
-	if !ok {
-		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
-	}
-
-	if len(result.Elements) != 3 {
-		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
-	}
-
-	testIntegerObject(t, result.Elements[0], 1)
-	testIntegerObject(t, result.Elements[1], 4)
-	testIntegerObject(t, result.Elements[2], 6)
-}
-
-func TestArrayIndexExpressions(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected interface{}
-	}{
-		{
-			"[1, 2, 3][0]",
-			1,
-		},
-		{
-			"[1, 2, 3][1]",
-			2,
-		},
-		{
-			"[1, 2, 3][2]",
-			3,
-		},
-		{
-			"let i = 0; [1][i];",
-			1,
-		},
-		{
-			"[1, 2, 3][1 + 1];",
-			3,
-		},
-		{
-			"let myArray = [1, 2, 3]; myArray[2];",
-			3,
-		},
-		{
-			"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];",
-			6,
-		},
-		{
-			"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]",
-			2,
-		},
-		{
-			"[1, 2, 3][3]",
-			nil,
-		},
-		{
-			"[1, 2, 3][-1]",
-			nil,
-		},
-	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
-		if ok {
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			testNullObject(t, evaluated)
-		}
-	}
-}
-
-func TestHashLiterals(t *testing.T) {
+func TestBuiltinContext(t *testing.T) {
 	input := `
-		let two = "two";
-		{
-			"one": 10 - 9,
-			two: 1 + 1,
-			"thr" + "ee": 6 / 2,
-			4: 4,
-			true: 5,
-			false: 6,
-		};
+		let name = input("name? ");
+		puts("hello", name);
+		puts(input());
 	`
+	var out bytes.Buffer
+	ctx := &object.ExecContext{Stdout: &out, Stdin: strings.NewReader("bob\n")}
 
-	evaluated := testEval(input)
-	result, ok := evaluated.(*object.Hash)
-	if !ok {
-		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
-	}
-
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
-	}
-
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
-	}
-
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
-		if !ok {
-			t.Errorf("no pair for given key in Pairs")
-		}
-		testIntegerObject(t, pair.Value, expectedValue)
-	}
-}
-
-func TestHashIndexExpressions(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected any
-	}{
-		{
-			`{"foo": 5}["foo"]`,
-			5,
-		},
-		{
-			`{"foo": 5}["bar"]`,
-			nil,
-		},
-		{
-			`let key = "foo"; {"foo": 5}[key]`,
-			5,
-		},
-		{
-			`{}["foo"]`,
-			nil,
-		},
-		{
-			`{5: 5}[5]`,
-			5,
-		},
-		{
-			`{true: 5}[true]`,
-			5,
-		},
-		{
-			`{false: 5}[false]`,
-			5,
-		},
-	}
-
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
-		if ok {
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			testNullObject(t, evaluated)
-		}
-	}
-}
-
-func TestWhileStatement(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected any
-	}{
-		{
-			`
-				let x = 0;
-				while (x < 0) {
-					x = x + 1;
-				}
-				x;
-			`,
-			0,
-		},
-		{
-			`
-				let x = 0;
-				while (x < 5) {
-					x = x + 1;
-				}
-				x;
-			`,
-			5,
-		},
-		{
-			`
-				let x = 0;
-				while (x < 5) {
-					x = x + 1;
-					break;
-				}
-				x;
-			`,
-			1,
-		},
-		{
-			`
-				let x = 0;
-				let y = 0;
-				while (x < 5) {
-					x = x + 1;
-					y -= 1;
-					continue;
-					y += 1;
-				}
-				y;
-			`,
-			-5,
-		},
-		{
-			`
-				let x = 0;
-				fn() {
-					while (true) {
-						x = x + 1;
-						if (x > 4) {
-							return null;
-						}
-					}
-				}()
-				x;
-			`,
-			5,
-		},
-		{
-			`
-				let x = 0;
-				while (true) {
-					x = x + 1;
-					if (x >= 5) {
-						break;
-					}
-				}
-				x;
-			`,
-			5,
-		},
-		{
-			`
-				let x = 0;
-				let y = 0;
-				while (x < 5) {
-					x += 1;
-					if (x > 3) {
-						continue
-					}
-					y += 1;
-				}
-				y;
-			`,
-			3,
-		},
-		{
-			`
-				let x = 0;
-				let y = 0;
-				while (x < 5) {
-					x += 1;
-					if (true) {
-						continue
-					}
-					y += 1;
-				}
-				y;
-			`,
-			0,
-		},
-	}
+	program := parser.New(lexer.New(input)).ParseProgram()
+	e := New()
+	e.SetContext(ctx)
+	e.Eval(program, object.NewEnvironment(), ScopeNone)
 
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
-		if ok {
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			testNullObject(t, evaluated)
-		}
+	expected := "name? hello bob\nnull\n"
+	if out.String() != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, out.String())
 	}
 }
 
@@ -1092,41 +62,17 @@ func testEval(input string) object.Object {
 	return Eval(program, env, ScopeNone)
 }
 
-func testNullObject(t *testing.T, obj object.Object) bool {
-	if obj != NULL {
-		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
-		return false
-	}
-	return true
-}
-
-func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
-	result, ok := obj.(*object.Integer)
-	if !ok {
-		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
-		return false
-	}
-
-	if result.Value != expected {
-		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
-		return false
-	}
-
-	return true
-}
-
-func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
-	result, ok := obj.(*object.Boolean)
-	if !ok {
-		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
-		return false
-	}
-
-	if result.Value != expected {
-		t.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
-		return false
+func BenchmarkFibonacci(b *testing.B) {
+	input := `
+		let fib = fn(n) {
+			if (n < 2) {
+				return n;
+			}
+			fib(n - 1) + fib(n - 2);
+		};
+		fib(20);
+	`
+	for i := 0; i < b.N; i++ {
+		testEval(input)
 	}
-
-	return true
-
 }
@@ -0,0 +1,97 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/labasubagia/interpreter/object"
+)
+
+// formatObjects formats args with the printf style verbs of format.
+// Verbs may have the flags, width and precision of Go, e.g. "%-8s" or "%.2f"
+func formatObjects(format string, args []object.Object) (string, *object.Error) {
+	var out strings.Builder
+	next := 0
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		}
+
+		start := i
+		i++
+		for i < len(format) && strings.IndexByte("+-# 0123456789.", format[i]) >= 0 {
+			i++
+		}
+		if i == len(format) {
+			return "", object.NewError(object.VALUE_ERROR, "format %q ends with an incomplete verb", format)
+		}
+
+		verb := format[i]
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		if next == len(args) {
+			return "", object.NewError(object.VALUE_ERROR, "missing argument for %s in format", format[start:i+1])
+		}
+
+		value, err := formatValue(verb, args[next])
+		if err != nil {
+			return "", err
+		}
+		next++
+		fmt.Fprintf(&out, format[start:i+1], value)
+	}
+
+	if next < len(args) {
+		return "", object.NewError(object.VALUE_ERROR, "too many arguments for format. got=%d, want=%d", len(args), next)
+	}
+	return out.String(), nil
+}
+
+// formatValue converts obj to the Go value the verb formats
+func formatValue(verb byte, obj object.Object) (any, *object.Error) {
+	switch verb {
+	case 's', 'v':
+		return obj.Inspect(), nil
+	case 'q':
+		if str, ok := obj.(*object.String); ok {
+			return str.Value, nil
+		}
+		return obj.Inspect(), nil
+	case 'd', 'x', 'X', 'o', 'b':
+		switch obj := obj.(type) {
+		case *object.Integer:
+			return obj.Value, nil
+		case *object.BigInteger:
+			return obj.Value, nil
+		}
+		if str, ok := obj.(*object.String); ok && (verb == 'x' || verb == 'X') {
+			return str.Value, nil
+		}
+		return nil, object.NewError(object.TYPE_ERROR, "%%%c in format needs INTEGER, got %s", verb, obj.Type())
+	case 'f', 'F', 'e', 'E', 'g', 'G':
+		if !isNumber(obj) {
+			return nil, object.NewError(object.TYPE_ERROR, "%%%c in format needs FLOAT, got %s", verb, obj.Type())
+		}
+		return toFloat(obj), nil
+	case 't':
+		if b, ok := obj.(*object.Boolean); ok {
+			return b.Value, nil
+		}
+		return nil, object.NewError(object.TYPE_ERROR, "%%t in format needs BOOLEAN, got %s", obj.Type())
+	case 'c':
+		if obj.Type() != object.INTEGER_OBJ {
+			return nil, object.NewError(object.TYPE_ERROR, "%%c in format needs INTEGER, got %s", obj.Type())
+		}
+		if code, ok := object.Int64(obj); ok && code >= 0 && code <= unicode.MaxRune {
+			return rune(code), nil
+		}
+		return nil, object.NewError(object.VALUE_ERROR, "%%c in format needs a character code, got %s", obj.Inspect())
+	default:
+		return nil, object.NewError(object.VALUE_ERROR, "unknown verb %%%c in format", verb)
+	}
+}
@@ -0,0 +1,101 @@
+package evaluator
+
+import (
+	"context"
+
+	"github.com/labasubagia/interpreter/ast"
+	"github.com/labasubagia/interpreter/object"
+)
+
+const LimitExceeded = "execution limit exceeded"
+
+// DefaultMaxDepth keeps deep recursion from overflowing the Go stack
+const DefaultMaxDepth = 10000
+
+// checkInterval is the number of steps between checks of the context
+const checkInterval = 1024
+
+// Limits bound the resources used by an evaluation, zero means unlimited.
+// MaxDepth zero means DefaultMaxDepth
+type Limits struct {
+	MaxSteps       int // number of evaluated nodes
+	MaxDepth       int // number of nested function calls
+	MaxAllocations int // number of allocated objects, approximated per node
+}
+
+// Depth is the maximum depth of nested function calls, DefaultMaxDepth when MaxDepth is zero
+func (l Limits) Depth() int {
+	if l.MaxDepth > 0 {
+		return l.MaxDepth
+	}
+	return DefaultMaxDepth
+}
+
+// EvalContext evaluates node with a new Evaluator, stopping when ctx is done or limits are exceeded
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, scope ScopeType, limits Limits) object.Object {
+	e := New()
+	e.SetLimits(limits)
+	return e.EvalContext(ctx, node, env, scope)
+}
+
+func (e *Evaluator) SetLimits(limits Limits) {
+	e.limits = limits
+}
+
+// EvalContext is Eval stopping when ctx is done, steps and allocations are counted from zero
+func (e *Evaluator) EvalContext(ctx context.Context, node ast.Node, env *object.Environment, scope ScopeType) object.Object {
+	e.done = ctx.Done()
+	e.steps, e.allocations = 0, 0
+	defer func() { e.done = nil }()
+
+	return e.Eval(node, env, scope)
+}
+
+// ApplyContext is Apply stopping when ctx is done, like EvalContext
+func (e *Evaluator) ApplyContext(ctx context.Context, fn object.Object, args []object.Object) object.Object {
+	e.done = ctx.Done()
+	e.steps, e.allocations = 0, 0
+	defer func() { e.done = nil }()
+
+	return e.Apply(fn, args)
+}
+
+// account counts node against the limits before it is evaluated
+func (e *Evaluator) account(node ast.Node) *object.Error {
+	e.steps++
+	if e.limits.MaxSteps > 0 && e.steps > e.limits.MaxSteps {
+		return object.NewError(object.LIMIT_ERROR, LimitExceeded)
+	}
+
+	if e.limits.MaxAllocations > 0 {
+		e.allocations += allocations(node)
+		if e.allocations > e.limits.MaxAllocations {
+			return object.NewError(object.LIMIT_ERROR, LimitExceeded)
+		}
+	}
+
+	if e.done != nil && e.steps%checkInterval == 0 {
+		select {
+		case <-e.done:
+			return object.NewError(object.LIMIT_ERROR, LimitExceeded)
+		default:
+		}
+	}
+	return nil
+}
+
+// allocations estimates the number of objects allocated by node itself
+func allocations(node ast.Node) int {
+	switch node := node.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.InterpolatedString, *ast.FunctionLiteral,
+		*ast.PrefixExpression, *ast.InfixExpression, *ast.AssignExpression, *ast.SliceExpression:
+		return 1
+	case *ast.ArrayLiteral:
+		return 1 + len(node.Elements)
+	case *ast.HashLiteral:
+		return 1 + len(node.Pairs)
+	case *ast.CallExpression, *ast.WhileStatement, *ast.ForStatement, *ast.ForInStatement, *ast.TryStatement:
+		return 1 // the environment
+	}
+	return 0
+}
@@ -0,0 +1,126 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/labasubagia/interpreter/ast"
+	"github.com/labasubagia/interpreter/lexer"
+	"github.com/labasubagia/interpreter/object"
+	"github.com/labasubagia/interpreter/parser"
+)
+
+// Modules loads the modules imported by programs, each module runs once
+// and its namespace is shared by every import of it
+type Modules struct {
+	SearchPath []string // directories searched after the one of the importing file
+
+	cache   map[string]object.Object // namespaces by absolute path
+	loading []loadingModule          // modules being loaded, the innermost last
+}
+
+type loadingModule struct {
+	path string
+	name string // as written in the import
+}
+
+func NewModules(searchPath ...string) *Modules {
+	return &Modules{SearchPath: searchPath, cache: map[string]object.Object{}}
+}
+
+// Resolve finds the file of the module name imported from the file importer,
+// empty when the importer is not a file, e.g. the REPL. Names starting with
+// ./ or ../ are only resolved relative to the importer
+func (m *Modules) Resolve(name, importer string) (string, bool) {
+	if filepath.IsAbs(name) {
+		return existingFile(name)
+	}
+
+	dirs := []string{"."}
+	if importer != "" {
+		dirs[0] = filepath.Dir(importer)
+	}
+	if !strings.HasPrefix(name, "./") && !strings.HasPrefix(name, "../") {
+		dirs = append(dirs, m.SearchPath...)
+	}
+
+	for _, dir := range dirs {
+		if path, ok := existingFile(filepath.Join(dir, name)); ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func existingFile(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	return abs, true
+}
+
+// Import returns the namespace of the module name imported from the file importer.
+// The first import parses the module and calls run to evaluate it
+func (m *Modules) Import(name, importer string, run func(path string, program *ast.Program) object.Object) object.Object {
+	path, ok := m.Resolve(name, importer)
+	if !ok {
+		return object.NewError(object.IMPORT_ERROR, "module not found: %s", name)
+	}
+	if namespace, ok := m.cache[path]; ok {
+		return namespace
+	}
+
+	for i, mod := range m.loading {
+		if mod.path == path {
+			cycle := []string{}
+			for _, mod := range m.loading[i:] {
+				cycle = append(cycle, mod.name)
+			}
+			cycle = append(cycle, name)
+			return object.NewError(object.IMPORT_ERROR, "import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return object.NewError(object.IMPORT_ERROR, "cannot read module %s: %s", name, err)
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return object.NewError(object.IMPORT_ERROR, "cannot parse module %s: %s", name, p.Errors()[0])
+	}
+
+	m.loading = append(m.loading, loadingModule{path: path, name: name})
+	namespace := run(path, program)
+	m.loading = m.loading[:len(m.loading)-1]
+
+	if isError(namespace) {
+		return namespace
+	}
+	m.cache[path] = namespace
+	return namespace
+}
+
+// Namespace is the value of an import, a hash of the exported names in the order they are declared,
+// or without any export the result of the module when it is a hash
+func Namespace(names []string, values map[string]object.Object, result object.Object) *object.Hash {
+	if len(names) == 0 {
+		if hash, ok := result.(*object.Hash); ok {
+			return hash
+		}
+	}
+
+	hash := object.NewHash()
+	for _, name := range names {
+		key := &object.String{Value: name}
+		hash.Set(key.HashKey(), object.HashPair{Key: key, Value: values[name]})
+	}
+	return hash
+}
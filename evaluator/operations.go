@@ -0,0 +1,66 @@
+package evaluator
+
+import "github.com/labasubagia/interpreter/object"
+
+// The operations below are the value semantics of the language,
+// they are exported so other backends (e.g. the vm) behave the same as Eval
+
+func PrefixOperation(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+func InfixOperation(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+func IndexOperation(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// SliceOperation returns left[start:end:step], null bounds are omitted
+func SliceOperation(left, start, end, step object.Object) object.Object {
+	return evalSliceExpression(left, start, end, step)
+}
+
+// CompoundAssignOperation computes the new value of `cur operator val`, e.g. x += 1
+func CompoundAssignOperation(operator string, cur, val object.Object) object.Object {
+	return evalCompoundAssign(operator, cur, val)
+}
+
+// IndexAssignOperation performs `name[index] operator val` on the collection left
+func IndexAssignOperation(name string, left, index object.Object, operator string, val object.Object) object.Object {
+	return evalIndexAssign(name, left, index, operator, val)
+}
+
+// SliceAssignOperation performs `left[start:end:step] operator val` on the array left
+func SliceAssignOperation(left, start, end, step object.Object, operator string, val object.Object) object.Object {
+	return evalSliceAssign(left, start, end, step, operator, val)
+}
+
+// InterpolateOperation joins the parts of an interpolated string
+func InterpolateOperation(parts []object.Object) *object.String {
+	return interpolate(parts)
+}
+
+// IterOperation returns an *object.Iterator for a for-in loop over obj
+func IterOperation(obj object.Object) object.Object {
+	return newIterator(obj)
+}
+
+// ThrowOperation turns the value of a throw statement into the raised error
+func ThrowOperation(val object.Object) *object.Error {
+	return throwError(val)
+}
+
+// CatchOperation is the value a catch block sees for err
+func CatchOperation(err *object.Error) *object.Hash {
+	return caughtError(err)
+}
+
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+func NativeBoolToBooleanObject(input bool) *object.Boolean {
+	return nativeBoolToBooleanObject(input)
+}
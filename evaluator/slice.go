@@ -0,0 +1,195 @@
+package evaluator
+
+import (
+	"math"
+
+	"github.com/labasubagia/interpreter/ast"
+	"github.com/labasubagia/interpreter/object"
+)
+
+// evalSliceBounds evaluates start, end and step of a slice, an omitted one is null
+func (e *Evaluator) evalSliceBounds(node *ast.SliceExpression, env *object.Environment, scope ScopeType) []object.Object {
+	bounds := []object.Object{NULL, NULL, NULL}
+	for i, exp := range []ast.Expression{node.Start, node.End, node.Step} {
+		if exp == nil {
+			continue
+		}
+		bound := e.Eval(exp, env, scope)
+		if isError(bound) {
+			return []object.Object{bound}
+		}
+		bounds[i] = bound
+	}
+	return bounds
+}
+
+// evalSliceExpression returns a new array, string or tuple with the elements of left[start:end:step]
+func evalSliceExpression(left, start, end, step object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		elements, err := sliceElements(left.Elements, start, end, step)
+		if err != nil {
+			return err
+		}
+		return &object.Array{Elements: elements}
+	case *object.Tuple:
+		elements, err := sliceElements(left.Elements, start, end, step)
+		if err != nil {
+			return err
+		}
+		return &object.Tuple{Elements: elements}
+	case *object.String:
+		runes := []rune(left.Value)
+		indexes, err := sliceIndexes(len(runes), start, end, step)
+		if err != nil {
+			return err
+		}
+		out := make([]rune, 0, len(indexes))
+		for _, i := range indexes {
+			out = append(out, runes[i])
+		}
+		return &object.String{Value: string(out)}
+	default:
+		return object.NewError(object.TYPE_ERROR, "slice operator not supported: %s", left.Type())
+	}
+}
+
+func sliceElements(elements []object.Object, start, end, step object.Object) ([]object.Object, *object.Error) {
+	indexes, err := sliceIndexes(len(elements), start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]object.Object, 0, len(indexes))
+	for _, i := range indexes {
+		out = append(out, elements[i])
+	}
+	return out, nil
+}
+
+// evalSliceAssign replaces the elements of the array cur[start:end:step] with the elements of val.
+// A plain slice may change the length of the array, a slice with a step must keep it
+func evalSliceAssign(cur, start, end, step object.Object, operator string, val object.Object) object.Object {
+	arrayObject, ok := cur.(*object.Array)
+	if !ok {
+		return object.NewError(object.TYPE_ERROR, "slice assignment not supported: %s", cur.Type())
+	}
+	if isCompoundAssignmentOperator(operator) {
+		return object.NewError(object.TYPE_ERROR, "unsupported assign %s[:] %s %s", cur.Type(), operator, val.Type())
+	}
+
+	var values []object.Object
+	switch val := val.(type) {
+	case *object.Array:
+		values = val.Elements
+	case *object.Tuple:
+		values = val.Elements
+	default:
+		return object.NewError(object.TYPE_ERROR, "can only assign ARRAY to a slice, got %s", val.Type())
+	}
+
+	n := len(arrayObject.Elements)
+	lo, hi, stride, err := sliceRange(n, start, end, step)
+	if err != nil {
+		return err
+	}
+
+	if stride == 1 {
+		hi = max(lo, hi)
+		elements := make([]object.Object, 0, n-(hi-lo)+len(values))
+		elements = append(elements, arrayObject.Elements[:lo]...)
+		elements = append(elements, values...)
+		elements = append(elements, arrayObject.Elements[hi:]...)
+		arrayObject.Elements = elements
+		return val
+	}
+
+	indexes := rangeIndexes(lo, hi, stride)
+	if len(indexes) != len(values) {
+		return object.NewError(object.VALUE_ERROR, "cannot assign %d elements to a slice of %d elements", len(values), len(indexes))
+	}
+	values = append([]object.Object(nil), values...) // val may be the array itself
+	for i, idx := range indexes {
+		arrayObject.Elements[idx] = values[i]
+	}
+	return val
+}
+
+// sliceIndexes returns the indexes of a sequence of length n selected by [start:end:step]
+func sliceIndexes(n int, start, end, step object.Object) ([]int, *object.Error) {
+	lo, hi, stride, err := sliceRange(n, start, end, step)
+	if err != nil {
+		return nil, err
+	}
+	return rangeIndexes(lo, hi, stride), nil
+}
+
+func rangeIndexes(lo, hi, stride int) []int {
+	var indexes []int
+	for i := lo; (stride > 0 && i < hi) || (stride < 0 && i > hi); i += stride {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// sliceRange resolves the bounds of a slice like Python, null bounds are omitted,
+// negative ones count from the end and the rest are clamped to the sequence
+func sliceRange(n int, start, end, step object.Object) (lo, hi, stride int, err *object.Error) {
+	stride = 1
+	if step != NULL {
+		s, ok := sliceInteger(step)
+		if !ok {
+			return 0, 0, 0, object.NewError(object.TYPE_ERROR, "slice step must be INTEGER, got %s", step.Type())
+		}
+		if s == 0 {
+			return 0, 0, 0, object.NewError(object.VALUE_ERROR, "slice step cannot be zero")
+		}
+		// a step past the end takes a single element, clamping it keeps the index from overflowing
+		limit := int64(n) + 2
+		stride = int(max(min(s, limit), -limit))
+	}
+
+	// a negative step walks from the end down to before the first element
+	lower, upper := 0, n
+	if stride < 0 {
+		lower, upper = -1, n-1
+	}
+	lo, hi = lower, upper
+	if stride < 0 {
+		lo, hi = upper, lower
+	}
+
+	if lo, err = sliceBound(start, n, lo, lower, upper); err != nil {
+		return 0, 0, 0, err
+	}
+	if hi, err = sliceBound(end, n, hi, lower, upper); err != nil {
+		return 0, 0, 0, err
+	}
+	return lo, hi, stride, nil
+}
+
+func sliceBound(bound object.Object, n, omitted, lower, upper int) (int, *object.Error) {
+	if bound == NULL {
+		return omitted, nil
+	}
+	i, ok := sliceInteger(bound)
+	if !ok {
+		return 0, object.NewError(object.TYPE_ERROR, "slice index must be INTEGER, got %s", bound.Type())
+	}
+
+	if i < 0 {
+		i += int64(n)
+	}
+	return int(max(min(i, int64(upper)), int64(lower))), nil
+}
+
+// sliceInteger returns the value of an integer bound or step, a big integer is
+// past either end of any sequence so it is clamped to the int64 range
+func sliceInteger(obj object.Object) (int64, bool) {
+	if b, ok := obj.(*object.BigInteger); ok {
+		if b.Value.Sign() < 0 {
+			return math.MinInt64, true
+		}
+		return math.MaxInt64, true
+	}
+	return object.Int64(obj)
+}
@@ -0,0 +1,2324 @@
+package enginetest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labasubagia/interpreter/evaluator"
+	"github.com/labasubagia/interpreter/object"
+	"github.com/labasubagia/interpreter/token"
+)
+
+func testIntegerExpression(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+
+		{"-5", -5},
+		{"-10", -10},
+
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"10 % 5", 0},
+		{"3 % 2", 1},
+		{"10 * 5 % 20", 10},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		testIntegerObject(t, obj, tt.expected)
+	}
+}
+
+func testFloatExpression(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"3.14", 3.14},
+		{"1e-9", 1e-9},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3.0},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"7 / 2.0", 3.5},
+		{"7.5 % 2", 1.5},
+		{"2 * 1.5 - 1", 2.0},
+		{"let x = 1; x += 0.5; x", 1.5},
+		{"let x = 1.5; x *= 2; x", 3.0},
+		{"let arr = [1]; arr[0] /= 4.0; arr[0]", 0.25},
+		{`let hash = {"a": 1.5}; hash["a"] -= 1; hash["a"]`, 0.5},
+		{"float(2)", 2.0},
+		{`float("1.25")`, 1.25},
+		{"int(3.9)", 3},
+		{"int(-3.9)", -3},
+		{`int("42")`, 42},
+		{"int(7)", 7},
+
+		{"1 == 1.0", true},
+		{"1.5 > 1", true},
+		{"2 <= 1.5", false},
+		{"0.1 + 0.2 != 0.3", true},
+		{"!0.0", true},
+		{"!1.5", false},
+
+		{`int("abc")`, "cannot convert \"abc\" to INTEGER"},
+		{`float([])`, "argument to `float` not supported, got ARRAY"},
+		{`1.5 + "a"`, "type mismatch: FLOAT + STRING"},
+		{`let x = 1.5; x += true`, "unsupported assign FLOAT += BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case float64:
+			testFloatObject(t, obj, expected)
+		case int:
+			testIntegerObject(t, obj, int64(expected))
+		case bool:
+			testBooleanObject(t, obj, expected)
+		case string:
+			errObj, ok := obj.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func testBigIntegerExpression(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"-9223372036854775807 - 1", "-9223372036854775808"},
+		{"let min = -9223372036854775807 - 1; -min", "9223372036854775808"},
+		{"let min = -9223372036854775807 - 1; min / -1", "9223372036854775808"},
+		{"let min = -9223372036854775807 - 1; min * -1", "9223372036854775808"},
+		{"100000000000000000000", "100000000000000000000"},
+		{"100000000000000000000 - 99999999999999999999", "1"},
+		{"100000000000000000000 / 3", "33333333333333333333"},
+		{"-100000000000000000000 % 7", "-2"},
+		{"100000000000000000000 > 9223372036854775807", "true"},
+		{"100000000000000000000 == 100000000000000000000", "true"},
+		{"100000000000000000000 * 0.5", "5e+19"},
+		{"let x = 9223372036854775807; x += 1; x", "9223372036854775808"},
+		{`let h = {100000000000000000000: "big"}; h[100000000000000000000]`, "big"},
+		{"[1, 2][100000000000000000000]", "null"},
+		{"[1, 2][-100000000000000000000]", "null"},
+		{`"abc"[100000000000000000000]`, "null"},
+		{`"abc"[-100000000000000000000]`, "null"},
+		{"tuple(1, 2)[100000000000000000000]", "null"},
+		{"tuple(1, 2)[-100000000000000000000]", "null"},
+		{"let a = [1, 2]; a[100000000000000000000] = 3", "ERROR: valid index range is 0 until 1. got=100000000000000000000"},
+		{`format("%c", 100000000000000000000)`, "ERROR: %c in format needs a character code, got 100000000000000000000"},
+		{`format("%c", 9731)`, "☃"},
+		{`int("100000000000000000000")`, "100000000000000000000"},
+		{"int(1e20)", "100000000000000000000"},
+		{"float(100000000000000000000)", "1e+20"},
+		{"let fact = fn(n) { if (n < 2) { return 1; } n * fact(n - 1) }; fact(25)", "15511210043330985984000000"},
+		{"1 / 0", "ERROR: division by zero"},
+		{"100000000000000000000 % 0", "ERROR: division by zero"},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		if obj.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, got=%s", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+}
+
+func testLogicalExpression(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"true || false && false", true},
+		{"1 && 2", 2},
+		{"0 && 2", 2},
+		{"null || 5", 5},
+		{"3 || 5", 3},
+		{"let x = 0; false && (x = 1); x", 0},
+		{"let x = 0; true || (x = 1); x", 0},
+		{"let x = 0; true && (x = 1); x", 1},
+		{"let f = fn() { 1 + true }; false && f()", false},
+		{"let f = fn() { 1 + true }; true || f()", true},
+		{"let f = fn() { 1 + true }; true && f()", "type mismatch: INTEGER + BOOLEAN"},
+		{"(1 + true) || true", "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, obj, int64(expected))
+		case bool:
+			testBooleanObject(t, obj, expected)
+		case string:
+			errObj, ok := obj.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func testBooleanExpression(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 >= 1", true},
+		{"2 >= 1", true},
+		{"1 >= 2", false},
+		{"1 <= 1", true},
+		{"1 <= 2", true},
+		{"2 <= 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"false != true", true},
+		{"(1 < 2) == true", true},
+		{"(1 < 2) == false", false},
+		{"(1 > 2) == true", false},
+		{"(1 > 2) == false", true},
+
+		{"null == null", true},
+		{"null != null", false},
+		{"let x = null; x == null", true},
+		{"let x = 12; x != null", true},
+		{"let x = false; x != null", true},
+		{"let x = true; x != null", true},
+		{"let x = 0; x != null", true},
+		{"let arr = [1,2,3]; arr[20] == null", true},
+		{"let arr = [1,2,3]; arr[2] != null", true},
+		{`let hash = {}; hash["a"] == null`, true},
+		{`let hash = {"a": 12}; hash["a"] == null`, false},
+
+		{`"a" == "a"`, true},
+		{`let a = "ab"; let b = "a" + "b"; a == b`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" != "a"`, false},
+		{`"a" < "b"`, true},
+		{`"b" < "a"`, false},
+		{`"ab" < "b"`, true},
+		{`"a" <= "a"`, true},
+		{`"b" > "a"`, true},
+		{`"a" >= "b"`, false},
+		{`"" < "a"`, true},
+		{`"a" == 1`, false},
+		{`"1" != 1`, true},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		testBooleanObject(t, obj, tt.expected)
+	}
+}
+
+func testBangOperator(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+		{"!0", true},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		testBooleanObject(t, obj, tt.expected)
+	}
+}
+
+func testIfElseExpressions(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, obj, int64(integer))
+		} else {
+			testNullObject(t, obj)
+		}
+	}
+}
+
+func testReturnStatements(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{
+			`
+			if (10 > 1) {
+				if (10 > 1) {
+					return 10;
+				}
+				return 1;
+			}
+			`,
+			10,
+		},
+	}
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		testIntegerObject(t, obj, tt.expected)
+	}
+}
+
+func testErrorHandling(t *testing.T, engine Engine) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{
+			"5 + true;",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"5 + true; 5;",
+			"type mismatch: INTEGER + BOOLEAN",
+		},
+		{
+			"-true",
+			"unknown operator: -BOOLEAN",
+		},
+		{
+			"true + false;",
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			"5; true + false; 5",
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			"if (10 > 1) { true + false; }",
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			`
+			if (10 > 1) {
+				if (10 > 1) {
+					return true + false;
+				}
+				return 1;
+			}
+			`,
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{
+			"foobar",
+			"identifier not found: foobar",
+		},
+		{
+			`"Hello" - "World"`,
+			"unknown operator: STRING - STRING",
+		},
+
+		{
+			`[1,2,3]["key"];`,
+			"index operator not supported: ARRAY",
+		},
+		{
+			`{"name": "Monkey"}[fn(x) { x }];`,
+			"unusable as hash key: FUNCTION",
+		},
+
+		// assign
+		{
+			"12 = 12;",
+			"invalid identifier when assign value: 12",
+		},
+		{
+			"[1,2][0] = 12;",
+			"invalid identifier using index",
+		},
+		{
+			`{"a": 12, "b": 3}["a"] = 8;`,
+			"invalid identifier using index",
+		},
+		{
+			"foobar = 12;",
+			"identifier not found: foobar",
+		},
+		{
+			"foo = bar;",
+			"identifier not found: bar",
+		},
+		{
+			"arr[0] = 12",
+			"identifier not found: arr",
+		},
+		{
+			"let arr = [1,2]; arr[x] = 12",
+			"identifier not found: x",
+		},
+		{
+			"let arr = [1,2]; arr[0] = y",
+			"identifier not found: y",
+		},
+		{
+			`let arr = [1,2]; arr[false] = 12`,
+			"index not supported: ARRAY[BOOLEAN]",
+		},
+		{
+			`let arr = [1,2]; arr["invalid"] = 12`,
+			"index not supported: ARRAY[STRING]",
+		},
+		{
+			`let arr = []; arr[0] = 12`,
+			"array is empty. cannot set at any index",
+		},
+		{
+			`let arr = [1,2]; arr[4] = 12`,
+			"valid index range is 0 until 1. got=4",
+		},
+		{
+			`let hash = {"a": 12}; hash[[1, {}]] = 12;`,
+			"unusable as hash key: ARRAY",
+		},
+
+		{
+			`let x = 12; x += "abc";`,
+			"unsupported assign INTEGER += STRING",
+		},
+		{
+			`
+				let arr = [false, 12];
+				arr[1] += "eat";
+				arr[1];
+			`,
+			"unsupported assign ARRAY[INTEGER] -> INTEGER += STRING",
+		},
+		{
+			`
+				let hash = {};
+				hash["a"] += 12;
+				hash["a"];
+			`,
+			"cannot assign key not exist: hash[a] += 12",
+		},
+
+		{
+			`
+				let hash = {"a": 12};
+				hash["a"] += "eat";
+				hash["a"];
+			`,
+			"unsupported assign HASH[STRING] -> INTEGER += STRING",
+		},
+
+		{
+			`
+				let x = 0;
+				while (true) {
+					x = x + 1;
+					if (x > 4) {
+						return null;
+					}
+				}
+				x;
+			`,
+			"return statement unsupported if while-loop not inside a function",
+		},
+		{
+			"while(x) {x += 1}",
+			"identifier not found: x",
+		},
+
+		{
+			`
+				fn() {
+					x;
+				}()
+			`,
+			"identifier not found: x",
+		},
+		{
+			"fn(x, y) { x }(1)",
+			"wrong number of arguments: want=2, got=1",
+		},
+		{
+			"fn(x) { x }(1, 2)",
+			"wrong number of arguments: want=1, got=2",
+		},
+		{
+			"fn(x, y = 1) { x }(1, 2, 3)",
+			"wrong number of arguments: want=1 to 2, got=3",
+		},
+		{
+			"fn(x = y) { x }()",
+			"identifier not found: y",
+		},
+		{
+			`
+				fn() {
+					break;
+				}()
+			`,
+			"break is only allowed inside a loop",
+		},
+		{
+			`
+				fn() {
+					continue;
+				}()
+			`,
+			"continue is only allowed inside a loop",
+		},
+
+		{
+			`	let i = 0;
+				while (i < 5) {
+					fn() {
+						continue;
+					}()
+					i += 1;
+				}
+			`,
+			"continue is only allowed inside a loop",
+		},
+		{"break; puts(1)", "break is only allowed inside a loop"},
+		{"let f = fn() { if (false) { break; } 1 }", "break is only allowed inside a loop"},
+		{"while (true) { let f = fn() { continue; }; }", "continue is only allowed inside a loop"},
+		{"fn(a, a) { a }(1, 2)", "duplicate parameter a"},
+		{"fn(a, ...a) { a }(1, 2)", "duplicate parameter a"},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+
+		errObj, ok := obj.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", obj, obj)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func testErrorStackTrace(t *testing.T, engine Engine) {
+	input := `let inner = fn(x) {
+	x + true
+};
+let outer = fn(x) {
+	let y = inner(x);
+	y
+};
+outer(2);`
+
+	obj := engine.Run(input)
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", obj, obj)
+	}
+
+	expectedStack := []object.StackFrame{
+		{Function: "outer", Position: token.Position{Offset: 78, Line: 8, Column: 1}},
+		{Function: "inner", Position: token.Position{Offset: 62, Line: 5, Column: 10}},
+	}
+	if len(errObj.Stack) != len(expectedStack) {
+		t.Fatalf("wrong stack length. expected=%d, got=%d", len(expectedStack), len(errObj.Stack))
+	}
+	for i, frame := range errObj.Stack {
+		if frame != expectedStack[i] {
+			t.Errorf("wrong stack frame %d. expected=%+v, got=%+v", i, expectedStack[i], frame)
+		}
+	}
+
+	expectedTraceback := `Traceback (most recent call last):
+  line 8, column 1, in <main>
+  line 5, column 10, in outer
+  line 2, column 2, in inner
+ERROR: type mismatch: INTEGER + BOOLEAN`
+	if errObj.Traceback() != expectedTraceback {
+		t.Errorf("wrong traceback. expected=%q, got=%q", expectedTraceback, errObj.Traceback())
+	}
+}
+
+func testCallbackStackTrace(t *testing.T, engine Engine) {
+	input := `let f = fn(x) { throw "x" }
+map([1], f)`
+
+	obj := engine.Run(input)
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", obj, obj)
+	}
+
+	expectedTraceback := `Traceback (most recent call last):
+  line 2, column 1, in <main>
+  line 1, column 17, in f
+ERROR: x`
+	if errObj.Traceback() != expectedTraceback {
+		t.Errorf("wrong traceback. expected=%q, got=%q", expectedTraceback, errObj.Traceback())
+	}
+}
+
+func testRethrowStackTrace(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"try { throw 5 } catch (e) { throw e }",
+			`Traceback (most recent call last):
+  line 1, column 7, in <main>
+ERROR: 5`,
+		},
+		{
+			`let f = fn() {
+	1 + true
+};
+try { f() } catch (e) { e["message"] = "again"; throw e }`,
+			`Traceback (most recent call last):
+  line 4, column 7, in <main>
+  line 2, column 2, in f
+ERROR: again`,
+		},
+		{
+			`try { throw 5 } catch (e) { throw {"message": e["message"]} }`,
+			`Traceback (most recent call last):
+  line 1, column 29, in <main>
+ERROR: 5`,
+		},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		errObj, ok := obj.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, obj, obj)
+			continue
+		}
+		if errObj.Traceback() != tt.expected {
+			t.Errorf("wrong traceback for %q.\nexpected=%s\ngot=%s", tt.input, tt.expected, errObj.Traceback())
+		}
+	}
+}
+
+func testImportStackTrace(t *testing.T, engine Engine) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib.newpl": "export let run = fn(x) {\n\tx + true\n};",
+		"a.newpl":   `let b = import "b.newpl";`,
+		"b.newpl":   "\nimport \"a.newpl\";",
+		"c.newpl":   "export let c = 1;\nc / 0;",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	main := filepath.Join(dir, "main.newpl")
+	file := func(name string) string { return `File "` + filepath.Join(dir, name) + `"` }
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let m = import \"lib.newpl\";\nm[\"run\"](1)",
+			`Traceback (most recent call last):
+  ` + file("main.newpl") + `, line 2, column 1, in <main>
+  ` + file("lib.newpl") + `, line 2, column 2, in run
+ERROR: type mismatch: INTEGER + BOOLEAN`,
+		},
+		{
+			`import "a.newpl"`,
+			`Traceback (most recent call last):
+  ` + file("main.newpl") + `, line 1, column 1, in <main>
+  ` + file("a.newpl") + `, line 1, column 9, in <module>
+  ` + file("b.newpl") + `, line 2, column 1, in <module>
+ERROR: import cycle: a.newpl -> b.newpl -> a.newpl`,
+		},
+		{
+			"let f = fn() { import \"c.newpl\" };\nf()",
+			`Traceback (most recent call last):
+  ` + file("main.newpl") + `, line 2, column 1, in <main>
+  ` + file("main.newpl") + `, line 1, column 16, in f
+  ` + file("c.newpl") + `, line 2, column 1, in <module>
+ERROR: division by zero`,
+		},
+	}
+
+	for _, tt := range tests {
+		obj := engine.RunFile(tt.input, main, evaluator.NewModules())
+		errObj, ok := obj.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, obj, obj)
+			continue
+		}
+		if errObj.Traceback() != tt.expected {
+			t.Errorf("wrong traceback for %q.\nexpected=%s\ngot=%s", tt.input, tt.expected, errObj.Traceback())
+		}
+	}
+}
+
+func testLetStatements(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, engine.Run(tt.input), tt.expected)
+	}
+}
+
+func testAssignExpressions(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a = 12;", 12},
+		{"let a = 5; a = 12; a;", 12},
+		{"let a = 5; a = 12; a = 13;  a;", 13},
+		{"let a = 5; a = 12; a = 5 * 5 + 5;  a;", 30},
+		{
+			`
+				let x = 12;
+				let f = fn() {
+					x = 50
+				}
+				f()
+				x
+			`,
+			50,
+		},
+		{
+			`
+				let x = 12;
+				let f = fn() {
+					let x = 50
+				}
+				f()
+				x
+			`,
+			12,
+		},
+		{
+			`
+				let x = 12;
+				let f = fn() {
+					x = 50
+					let fa = fn() {
+						x = 55
+					}
+					fa()
+				}
+				f()
+				x
+			`,
+			55,
+		},
+		{
+			`
+				let x = 12;
+				let f = fn() {
+					let x = 50
+					let fa = fn() {
+						let x = 55
+					}
+					fa()
+				}
+				f()
+				x
+			`,
+			12,
+		},
+
+		{"let arr = [1,2,3,4]; arr[1+1] = 12; arr[2]", 12},
+		{`let hash = {"a": 12, "b": 4}; hash["a"] = 40; hash["a"];`, 40},
+		{`let hash = {}; hash["z"] = 22; hash["z"];`, 22},
+		{
+			`
+				let arr = [4, 2, 1, 5];
+
+				let fa = fn() {
+					arr[2] = 44;
+				}
+
+				fa();
+				arr[2];
+			`,
+			44,
+		},
+		{
+			`
+				let arr = [4, 2, 1, 5];
+
+				let fa = fn() {
+					let arr = [1,2,3]
+					arr[2] = 44;
+				}
+
+				fa();
+				arr[2];
+			`,
+			1,
+		},
+		{
+			`
+				let arr = [4, 2, 1, 5];
+
+				fn() {
+					arr[2] = 44;
+					fn(){
+						arr[2] = 66;
+					}()
+				}()
+
+				arr[2];
+			`,
+			66,
+		},
+		{
+			`
+				let hash = {};
+
+				fn() {
+					hash["a"] = 66;
+				}()
+
+				hash["a"];
+			`,
+			66,
+		},
+		{
+			`
+				let hash = {};
+
+				fn() {
+					hash["a"] = 66;
+					fn(){
+						hash["a"] = 99;
+					}()
+				}()
+
+				hash["a"];
+			`,
+			99,
+		},
+		{
+			`
+				let hash = {"a": 10};
+
+				fn() {
+					let hash = {}
+					fn(){
+						hash["a"] = 99;
+					}()
+				}()
+
+				hash["a"];
+			`,
+			10,
+		},
+
+		{"let x = 5; x += 10 * 2; x", 25},
+		{"let x = 5; x -= 10; x", -5},
+		{"let x = 5; x *= 10; x", 50},
+		{"let x = 20; x /= 10; x", 2},
+		{"let x = 20; x /= 10 / 2; x", 4},
+		{"let x = 20; x %= 10 / 2; x", 0},
+
+		{
+			`
+			let arr = [1,5,3,4];
+			arr[2] += 20
+			`,
+			23,
+		},
+		{
+			`
+			let arr = [1,5,3,4];
+			arr[2] -= 20
+			`,
+			-17,
+		},
+		{
+			`
+			let arr = [1,5,3,4];
+			arr[2] %= 2
+			`,
+			1,
+		},
+		{
+			`
+				let hash = {"a": 2};
+				hash["a"] *= 12;
+				hash["a"];
+			`,
+			24,
+		},
+		{
+			`
+				let hash = {"a": 2};
+				hash["a"] /= 2;
+				hash["a"];
+			`,
+			1,
+		},
+		{
+			`
+				let hash = {"a": 2};
+				hash["a"] %= 2;
+				hash["a"];
+			`,
+			0,
+		},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, engine.Run(tt.input), tt.expected)
+	}
+}
+
+func testFunctionApplication(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let add = fn(x, y = 10) { x + y; }; add(5);", 15},
+		{"let add = fn(x, y = 10) { x + y; }; add(5, 1);", 6},
+		{"let add = fn(x = 1, y = x + 1) { x + y; }; add();", 3},
+		{"let add = fn(x = 1, y = x + 1) { x + y; }; add(5);", 11},
+		{"let n = 0; let next = fn(step = n + 1) { n = step; }; next(); next(); next(10); next();", 11},
+		{"let f = fn(x, y = fn() { x * 2 }) { y() }; f(4);", 8},
+		{"let f = fn(x = 1) { let y = 2; x + y }; f() + f(10);", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, engine.Run(tt.input), tt.expected)
+	}
+}
+
+func testFunctionWithoutValue(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn() {}()", "null"},
+		{"fn() { let x = 1; }()", "null"},
+		{"fn() {}() == null", "true"},
+		{`"${fn() {}()}"`, "null"},
+		{`format("%v", fn() {}())`, "null"},
+		{"tuple(fn() {}())", "ERROR: unusable as tuple element: NULL"},
+		{"[fn() {}()]", "[null]"},
+		{"let g = fn(...xs) { xs }; g(fn() {}())", "[null]"},
+		{"fn(a = fn() {}()) { a }()", "null"},
+		{`let h = {}; h["a"] = fn() {}(); h["a"]`, "null"},
+		{`let h = {}; h["a"] = fn() {}(); len(h)`, "1"},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		if obj == nil || obj.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%v", tt.input, tt.expected, obj)
+		}
+	}
+}
+
+func testRestAndSpread(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"let f = fn(...args) { args }; f()", []int64{}},
+		{"let f = fn(...args) { args }; f(1, 2, 3)", []int64{1, 2, 3}},
+		{"let f = fn(a, ...rest) { rest }; f(1)", []int64{}},
+		{"let f = fn(a, ...rest) { rest }; f(1, 2, 3)", []int64{2, 3}},
+		{"let f = fn(a, b = 5, ...rest) { push(rest, a + b) }; f(1)", []int64{6}},
+		{"let f = fn(a, b = 5, ...rest) { push(rest, a + b) }; f(1, 2, 3)", []int64{3, 3}},
+		{"let f = fn(a, ...rest) { let x = 10; [a, x, len(rest)] }; f(1, 2, 3, 4)", []int64{1, 10, 3}},
+		{"let add = fn(a, b) { a + b }; add(...[1, 2])", 3},
+		{"let add = fn(a, b) { a + b }; add(1, ...[2])", 3},
+		{"let add = fn(a, b) { a + b }; let args = [3, 4]; add(...args)", 7},
+		{"len(...[[1, 2]])", 2},
+		{"[...[1, 2], 3, ...[], ...[4]]", []int64{1, 2, 3, 4}},
+		{"let a = [1]; let b = [...a]; b[0] = 2; a[0]", 1},
+		{
+			`
+				let sum = fn(...numbers) {
+					let total = 0;
+					for (n in numbers) {
+						total += n;
+					}
+					total
+				};
+				sum(1, 2, 3) + sum(...[10, 20])
+			`,
+			36,
+		},
+		{"let f = fn(a, ...rest) { a }; f()", "wrong number of arguments: want at least 1, got=0"},
+		{"let f = fn(a, b) { a }; f(...[1, 2, 3])", "wrong number of arguments: want=2, got=3"},
+		{"let f = fn(a) { a }; f(...1)", "spread operator not supported: INTEGER"},
+		{"[...null]", "spread operator not supported: NULL"},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, obj, int64(expected))
+		case []int64:
+			arr, ok := obj.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", obj, obj)
+				continue
+			}
+			if len(arr.Elements) != len(expected) {
+				t.Errorf("wrong number of elements for %q. want=%d, got=%d", tt.input, len(expected), len(arr.Elements))
+				continue
+			}
+			for i, el := range expected {
+				testIntegerObject(t, arr.Elements[i], el)
+			}
+		case string:
+			errObj, ok := obj.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func testTryCatch(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let x = 0; try { x = 1; } catch (e) { x = 2; } x`, 1},
+		{`let x = 0; try { throw "boom"; x = 1; } catch (e) { x = 2; } x`, 2},
+		{`let msg = ""; try { throw "boom"; } catch (e) { msg = e["message"]; } msg`, "boom"},
+		{`let t = ""; try { throw "boom"; } catch (e) { t = e["type"]; } t`, "Error"},
+		{`let t = ""; try { 1 + true; } catch (e) { t = e["type"] + ": " + e["message"]; } t`, "TypeError: type mismatch: INTEGER + BOOLEAN"},
+		{`let t = ""; try { y; } catch (e) { t = e["type"]; } t`, "NameError"},
+		{`let t = ""; try { 1 / 0; } catch (e) { t = e["type"]; } t`, "ValueError"},
+		{`let t = ""; try { {}[{}]; } catch (e) { t = e["type"]; } t`, "TypeError"},
+		{`let t = ""; try { len(1); } catch (e) { t = e["type"]; } t`, "TypeError"},
+		{`let t = ""; try { throw {"message": "bad", "type": "MyError"}; } catch (e) { t = e["type"] + e["message"]; } t`, "MyErrorbad"},
+		{`let t = ""; try { throw 42; } catch (e) { t = e["message"]; } t`, "42"},
+		{`let x = 0; try { throw "boom"; } catch { x = 1; } x`, 1},
+		{`let x = 0; try { x = 1; } finally { x = x + 10; } x`, 11},
+		{`let x = 0; try { throw "boom"; } catch (e) { x = 1; } finally { x = x + 10; } x`, 11},
+		{`let f = fn() { throw "deep"; }; let g = fn() { f() }; let m = ""; try { g(); } catch (e) { m = e["message"]; } m`, "deep"},
+		{`let f = fn() { throw "deep"; }; let n = 0; try { f(); } catch (e) { n = len(e["stack"]); } n`, 2},
+		{`let f = fn() { try { return 1; } finally { let x = 2; } }; f()`, 1},
+		{`let f = fn() { try { return 1; } finally { return 2; } }; f()`, 2},
+		{`let f = fn() { try { throw "a"; } catch (e) { return e["message"]; } }; f()`, "a"},
+		{`let f = fn() { try { throw "a"; } finally { return 3; } }; f()`, 3},
+		{`let f = fn() { let x = 1; try { throw "a"; } catch (e) { x = 2; } return x; }; f()`, 2},
+		{
+			`
+				let log = [];
+				for (let i = 0; i < 5; i += 1) {
+					try {
+						if (i == 1) { continue; }
+						if (i == 3) { break; }
+						log = push(log, i);
+					} finally {
+						log = push(log, 10 + i);
+					}
+				}
+				len(log) * 100 + log[0] + log[len(log) - 1]
+			`,
+			613,
+		},
+		{
+			`
+				let sum = 0;
+				for (x in [1, 2, 3]) {
+					try {
+						if (x == 2) { throw "skip"; }
+						sum += x;
+					} catch (e) {
+						sum += 100;
+					}
+				}
+				sum
+			`,
+			104,
+		},
+		{
+			`
+				let order = "";
+				try {
+					try {
+						throw "inner";
+					} catch (e) {
+						order = order + "catch,";
+						throw e["message"] + " again";
+					} finally {
+						order = order + "finally,";
+					}
+				} catch (e) {
+					order = order + e["message"];
+				}
+				order
+			`,
+			"catch,finally,inner again",
+		},
+		{
+			`
+				let order = "";
+				try {
+					try { throw "inner"; } finally { order = order + "finally,"; }
+				} catch (e) {
+					order = order + e["message"];
+				}
+				order
+			`,
+			"finally,inner",
+		},
+		{
+			`
+				let counter = fn() {
+					let n = 0;
+					fn() { n += 1; if (n > 1) { throw "twice"; } n }
+				};
+				let c = counter();
+				let r = 0;
+				try { c(); c(); } catch (e) { r = e["message"]; }
+				r
+			`,
+			"twice",
+		},
+		{`try { throw "boom"; } catch (e) { 1 }`, nil},
+		{`throw "uncaught";`, &object.Error{Message: "uncaught"}},
+		{`throw {"message": "bad", "type": "ValueError"};`, &object.Error{Kind: object.VALUE_ERROR, Message: "bad"}},
+		{`try { throw "a"; } catch (e) { throw "b"; }`, &object.Error{Message: "b"}},
+		{`try { throw "a"; } finally { 1 }`, &object.Error{Message: "a"}},
+		{`try { 1 } finally { throw "b"; }`, &object.Error{Message: "b"}},
+		{`let s = []; try { -true; } catch (e) { s = e["stack"]; } s[0]`, "line 1, column 19, in <main>"},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, obj, int64(expected))
+		case string:
+			str, ok := obj.(*object.String)
+			if !ok {
+				t.Errorf("object is not String for %q. got=%T (%+v)", tt.input, obj, obj)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. want=%q, got=%q", expected, str.Value)
+			}
+		case *object.Error:
+			errObj, ok := obj.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %q. got=%T (%+v)", tt.input, obj, obj)
+				continue
+			}
+			if errObj.Message != expected.Message || errObj.KindName() != expected.KindName() {
+				t.Errorf("wrong error. want=%s %q, got=%s %q", expected.KindName(), expected.Message, errObj.KindName(), errObj.Message)
+			}
+		case nil:
+			testNullObject(t, obj)
+		}
+	}
+}
+
+func testImport(t *testing.T, engine Engine) {
+	dir := t.TempDir()
+	libDir := filepath.Join(dir, "lib")
+	files := map[string]string{
+		"math.newpl":         `export let square = fn(x) { x * x }; export let two = 2; let hidden = 3;`,
+		"hash.newpl":         `let double = fn(x) { x * 2 }; {"double": double}`,
+		"counter.newpl":      `let n = 0; export let inc = fn() { n += 1 }; export let get = fn() { n };`,
+		"uses.newpl":         `let m = import "./math.newpl"; export let cube = fn(x) { m["square"](x) * x };`,
+		"secret.newpl":       `export let leak = fn() { secret };`,
+		"broken.newpl":       `let = 1;`,
+		"fails.newpl":        `export let x = 1 / 0;`,
+		"a.newpl":            `import "b.newpl"; export let a = 1;`,
+		"b.newpl":            `import "a.newpl"; export let b = 1;`,
+		"lib/strings.newpl":  `export let greet = fn(name) { "hello " + name };`,
+		"lib/relative.newpl": `export let value = import "./strings.newpl"["greet"]("lib");`,
+	}
+	if err := os.Mkdir(libDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let m = import "math.newpl"; m["square"](3) + m["two"]`, 11},
+		{`let m = import "math.newpl"; m["hidden"]`, nil},
+		{`let h = import "hash.newpl"; h["double"](4)`, 8},
+		{`let u = import "uses.newpl"; u["cube"](3)`, 27},
+		{`let a = import "counter.newpl"; let b = import "counter.newpl"; a["inc"](); a["inc"](); b["get"]()`, 2},
+		{`let s = import "strings.newpl"; s["greet"]("you")`, "hello you"},
+		{`let r = import "lib/relative.newpl"; r["value"]`, "hello lib"},
+		{`let secret = 1; let s = import "secret.newpl"; s["leak"]()`, &object.Error{Kind: object.NAME_ERROR, Message: "identifier not found: secret"}},
+		{`import "missing.newpl"`, &object.Error{Kind: object.IMPORT_ERROR, Message: "module not found: missing.newpl"}},
+		{`import "./strings.newpl"`, &object.Error{Kind: object.IMPORT_ERROR, Message: "module not found: ./strings.newpl"}},
+		{`import "broken.newpl"`, &object.Error{Kind: object.IMPORT_ERROR, Message: "cannot parse module broken.newpl: 1:5: expected next token to be IDENT, got = instead"}},
+		{`import "fails.newpl"`, &object.Error{Kind: object.VALUE_ERROR, Message: "division by zero"}},
+		{`import "a.newpl"`, &object.Error{Kind: object.IMPORT_ERROR, Message: "import cycle: a.newpl -> b.newpl -> a.newpl"}},
+		{`let t = ""; try { import "missing.newpl"; } catch (e) { t = e["type"]; } t`, "ImportError"},
+	}
+
+	for _, tt := range tests {
+		obj := engine.RunFile(tt.input, filepath.Join(dir, "main.newpl"), evaluator.NewModules(libDir))
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, obj, int64(expected))
+		case string:
+			str, ok := obj.(*object.String)
+			if !ok {
+				t.Errorf("object is not String for %q. got=%T (%+v)", tt.input, obj, obj)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. want=%q, got=%q", expected, str.Value)
+			}
+		case *object.Error:
+			errObj, ok := obj.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %q. got=%T (%+v)", tt.input, obj, obj)
+				continue
+			}
+			if errObj.Message != expected.Message || errObj.KindName() != expected.KindName() {
+				t.Errorf("wrong error. want=%s %q, got=%s %q", expected.KindName(), expected.Message, errObj.KindName(), errObj.Message)
+			}
+		case nil:
+			testNullObject(t, obj)
+		}
+	}
+}
+
+func testHigherOrderBuiltins(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, []int64{2, 4, 6}},
+		{`map([], fn(x) { x })`, []int64{}},
+		{`let k = 10; map([1, 2], fn(x) { x + k })`, []int64{11, 12}},
+		{`map([[1], [1, 2]], len)`, []int64{1, 2}},
+		{`filter([1, 2, 3, 4], fn(x) { x % 2 == 0 })`, []int64{2, 4}},
+		{`reduce([1, 2, 3], fn(acc, x) { acc + x })`, 6},
+		{`reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)`, 16},
+		{`reduce([], fn(acc, x) { acc + x }, 0)`, 0},
+		{`any([1, 2, 3], fn(x) { x > 2 })`, true},
+		{`any([], fn(x) { true })`, false},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, true},
+		{`all([1, 2, 3], fn(x) { x > 1 })`, false},
+		{`find([1, 2, 3], fn(x) { x > 1 })`, 2},
+		{`find([1, 2, 3], fn(x) { x > 5 })`, nil},
+		{`sort([3, 1, 2])`, []int64{1, 2, 3}},
+		{`sort([3, 1, 2], fn(a, b) { b - a })`, []int64{3, 2, 1}},
+		{`let arr = [3, 1, 2]; sort(arr); arr`, []int64{3, 1, 2}},
+		{`reverse([1, 2, 3])`, []int64{3, 2, 1}},
+		{`map([1, 2], fn(x) {})[1]`, nil},
+		{`map([1], fn(x) { let y = x; })[0]`, nil},
+		{`filter([1], fn(x) {})`, []int64{}},
+		{`reduce([1, 2], fn(a, b) {}, 0)`, nil},
+		{`any([1], fn(x) {})`, false},
+		{`all([1], fn(x) {})`, false},
+		{`find([1], fn(x) {})`, nil},
+		{`let total = 0; map([1, 2, 3], fn(x) { total += x; }); total`, 6},
+		{`let f = fn() { for (x in [1, 2]) { map([x], fn(y) { return y; }); } 5 }; f()`, 5},
+		{`map([1, 2], fn(x) { map([x], fn(y) { y * 10 })[0] })`, []int64{10, 20}},
+		{`let r = 0; try { map([1, 2], fn(x) { if (x == 2) { throw "two"; } x }); } catch (e) { r = 1; } r`, 1},
+		{`let f = fn() { try { filter([1], fn(x) { throw "f"; }) } catch (e) { return 2; } }; f()`, 2},
+		{`map([1, 2], fn(x) { let r = x; try { throw "a"; } catch (e) { r = -x; } r })`, []int64{-1, -2}},
+		{`map([1], fn(x, y) { x })`, "wrong number of arguments: want=2, got=1"},
+		{`map([1], 1)`, "not a function: INTEGER"},
+		{`map([1], fn(x) { x + true })`, "type mismatch: INTEGER + BOOLEAN"},
+		{`reduce([], fn(acc, x) { acc + x })`, "reduce of empty array with no initial value"},
+		{`reduce([1], fn(acc, x) { acc }, 1, 2)`, "wrong number of arguments. got=4, want=2 or 3"},
+		{`sort([1, "a"])`, "cannot compare STRING and INTEGER"},
+		{`sort([1, 2], fn(a, b) { true })`, "comparator of `sort` must return a number, got BOOLEAN"},
+		{`sort([2, 1], fn(a, b) {})`, "comparator of `sort` must return a number, got NULL"},
+		{`reverse(1)`, "argument to `reverse` must be ARRAY, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, obj, int64(expected))
+		case bool:
+			testBooleanObject(t, obj, expected)
+		case []int64:
+			arr, ok := obj.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array for %q. got=%T (%+v)", tt.input, obj, obj)
+				continue
+			}
+			if len(arr.Elements) != len(expected) {
+				t.Errorf("wrong number of elements for %q. want=%d, got=%d", tt.input, len(expected), len(arr.Elements))
+				continue
+			}
+			for i, el := range expected {
+				testIntegerObject(t, arr.Elements[i], el)
+			}
+		case string:
+			errObj, ok := obj.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %q. got=%T (%+v)", tt.input, obj, obj)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		case nil:
+			testNullObject(t, obj)
+		}
+	}
+}
+
+func testStringBuiltins(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`split("a,b,,c", ",")`, []string{"a", "b", "", "c"}},
+		{`split("  a b  c ")`, []string{"a", "b", "c"}},
+		{`split("abc", "")`, []string{"a", "b", "c"}},
+		{`join(["a", "b", "c"], ", ")`, "a, b, c"},
+		{`join(["a", "b"])`, "ab"},
+		{`join([], "-")`, ""},
+		{`join(split("a b", " "), "+")`, "a+b"},
+		{`trim("  hi  ")`, "hi"},
+		{`trim("xxhixx", "x")`, "hi"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`upper("Hello")`, "HELLO"},
+		{`lower("Hello")`, "hello"},
+		{`contains("hello", "ell")`, true},
+		{`contains("hello", "z")`, false},
+		{`starts_with("hello", "he")`, true},
+		{`ends_with("hello", "he")`, false},
+		{`index_of("hello", "l")`, 2},
+		{`index_of("héllo", "l")`, 2},
+		{`index_of("hello", "z")`, -1},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", 0)`, ""},
+		{`substr("hello", 1, 3)`, "ell"},
+		{`substr("hello", 3)`, "lo"},
+		{`substr("hello", -3, 2)`, "ll"},
+		{`substr("hello", 10)`, ""},
+		{`substr("héllo", 1, 2)`, "él"},
+		{`format("%s is %d", "x", 42)`, "x is 42"},
+		{`format("%.2f|%5s|%-3d|", 3.14159, "ab", 7)`, "3.14|   ab|7  |"},
+		{`format("%v %v %s", [1, 2], true, null)`, "[1, 2] true null"},
+		{`format("%q %x %t %%", "a", 255, false)`, `"a" ff false %`},
+		{`format("%d", 100000000000000000000)`, "100000000000000000000"},
+		{`format("%f", 1)`, "1.000000"},
+		{`sprintf("%03d", 5)`, "005"},
+		{`format("no verbs")`, "no verbs"},
+		{`split(1, ",")`, &object.Error{Kind: object.TYPE_ERROR, Message: "argument to `split` must be STRING, got INTEGER"}},
+		{`split("a", ",", ",")`, &object.Error{Kind: object.TYPE_ERROR, Message: "wrong number of arguments. got=3, want=1 or 2"}},
+		{`join([1, 2], ",")`, &object.Error{Kind: object.TYPE_ERROR, Message: "elements of `join` must be STRING, got INTEGER"}},
+		{`repeat("a", -1)`, &object.Error{Kind: object.VALUE_ERROR, Message: "count of `repeat` must not be negative, got -1"}},
+		{`substr("a", 0, -1)`, &object.Error{Kind: object.VALUE_ERROR, Message: "length of `substr` must not be negative, got -1"}},
+		{`repeat("a", 100000000000000000000)`, &object.Error{Kind: object.VALUE_ERROR, Message: "count of `repeat` is out of range, got 100000000000000000000"}},
+		{`substr("abc", 100000000000000000000)`, &object.Error{Kind: object.VALUE_ERROR, Message: "start of `substr` is out of range, got 100000000000000000000"}},
+		{`substr("abc", 0, -100000000000000000000)`, &object.Error{Kind: object.VALUE_ERROR, Message: "length of `substr` is out of range, got -100000000000000000000"}},
+		{`format("%d", "a")`, &object.Error{Kind: object.TYPE_ERROR, Message: "%d in format needs INTEGER, got STRING"}},
+		{`format("%s %s", "a")`, &object.Error{Kind: object.VALUE_ERROR, Message: "missing argument for %s in format"}},
+		{`format("%s", "a", "b")`, &object.Error{Kind: object.VALUE_ERROR, Message: "too many arguments for format. got=2, want=1"}},
+		{`format("%y", 1)`, &object.Error{Kind: object.VALUE_ERROR, Message: "unknown verb %y in format"}},
+		{`format("100%")`, &object.Error{Kind: object.VALUE_ERROR, Message: `format "100%" ends with an incomplete verb`}},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, obj, int64(expected))
+		case bool:
+			testBooleanObject(t, obj, expected)
+		case string:
+			testStringObject(t, obj, expected)
+		case []string:
+			arr, ok := obj.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array for %q. got=%T (%+v)", tt.input, obj, obj)
+				continue
+			}
+			if len(arr.Elements) != len(expected) {
+				t.Errorf("wrong number of elements for %q. want=%d, got=%d", tt.input, len(expected), len(arr.Elements))
+				continue
+			}
+			for i, el := range expected {
+				testStringObject(t, arr.Elements[i], el)
+			}
+		case *object.Error:
+			errObj, ok := obj.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %q. got=%T (%+v)", tt.input, obj, obj)
+				continue
+			}
+			if errObj.Message != expected.Message || errObj.KindName() != expected.KindName() {
+				t.Errorf("wrong error. want=%s %q, got=%s %q", expected.KindName(), expected.Message, errObj.KindName(), errObj.Message)
+			}
+		}
+	}
+}
+
+func testClosures(t *testing.T, engine Engine) {
+	input := `
+		let newAdder = fn(x) {
+			fn(y) { x + y };
+		};
+		let addTwo = newAdder(2);
+		addTwo(2);
+	`
+	testIntegerObject(t, engine.Run(input), 4)
+}
+
+func testStringLiteral(t *testing.T, engine Engine) {
+	input := `"Hello World!"`
+
+	obj := engine.Run(input)
+	str, ok := obj.(*object.String)
+	if !ok {
+		t.Fatalf("obj is not String. got=%T (%+v)", obj, obj)
+	}
+
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func testStringConcatenation(t *testing.T, engine Engine) {
+	input := `"Hello" + " " + "World!"`
+	obj := engine.Run(input)
+	str, ok := obj.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", obj, obj)
+	}
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func testStringInterpolation(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let n = 21; "n = ${n * 2}"`, "n = 42"},
+		{`"${1}${2.5} ${true} ${null} ${[1, "a"]}"`, "12.5 true null [1, a]"},
+		{`let name = "x"; "hello ${name}!"`, "hello x!"},
+		{`"outer ${"inner ${1 + 1}"}"`, "outer inner 2"},
+		{`"${ {"a": 1}["a"] }"`, "1"},
+		{`let f = fn(x) { "<${x}>" }; f(1) + f(2)`, "<1><2>"},
+		{`"\${n}"`, "${n}"},
+		{`"${missing}"`, &object.Error{Kind: object.NAME_ERROR, Message: "identifier not found: missing"}},
+		{`"${1 + true}"`, &object.Error{Kind: object.TYPE_ERROR, Message: "type mismatch: INTEGER + BOOLEAN"}},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case string:
+			testStringObject(t, obj, expected)
+		case *object.Error:
+			errObj, ok := obj.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %q. got=%T (%+v)", tt.input, obj, obj)
+				continue
+			}
+			if errObj.Message != expected.Message || errObj.KindName() != expected.KindName() {
+				t.Errorf("wrong error. want=%s %q, got=%s %q", expected.KindName(), expected.Message, errObj.KindName(), errObj.Message)
+			}
+		}
+	}
+}
+
+func testBuiltinFunctions(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len("héllo")`, 5},
+		{`len("日本語")`, 3},
+		{`len("a\tb\n")`, 4},
+		{"len(`a\\tb`)", 4},
+		{`len("""
+ab""")`, 2},
+		{`len([])`, 0},
+		{`len([1, 2, 3])`, 3},
+		{`len({"a": 1, "b": 2, "c": false})`, 3},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+
+		{`first([])`, nil},
+		{`first([1])`, 1},
+		{`first([2,1,3])`, 2},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`first([1], [2])`, "wrong number of arguments. got=2, want=1"},
+
+		{`last([])`, nil},
+		{`last([1])`, 1},
+		{`last([2,1,3])`, 3},
+		{`last(1)`, "argument to `last` must be ARRAY, got INTEGER"},
+		{`last([1], [2])`, "wrong number of arguments. got=2, want=1"},
+
+		{`rest([])`, []int64{}},
+		{`rest([1])`, []int64{}},
+		{`rest([1,2])`, []int64{2}},
+		{`let a = [1,2,3]; rest(rest(rest(a)));`, []int64{}},
+		{`let a = []; rest(rest(rest(rest(rest(a)))));`, []int64{}},
+		{`rest(1)`, "argument to `rest` must be ARRAY, got INTEGER"},
+		{`rest([1], [2])`, "wrong number of arguments. got=2, want=1"},
+
+		{`push([], 1)`, []int64{1}},
+		{`push([1], 2)`, []int64{1, 2}},
+		{`push([1,2], 3)`, []int64{1, 2, 3}},
+		{`push(1, 2)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`push([])`, "wrong number of arguments. got=1, want=2"},
+		{`push([1], 2, 3)`, "wrong number of arguments. got=3, want=2"},
+
+		{
+			`
+			let map = fn(arr, f) {
+				let iter = fn(arr, accumulated) {
+					if (len(arr) == 0) {
+						accumulated
+					} else {
+						iter(rest(arr), push(accumulated, f(first(arr))));
+					}
+				};
+				iter(arr, []);
+			};
+			let a = [1, 2, 3, 4];
+			let double = fn(x) { x * 2 };
+			map(a, double);
+			`,
+			[]int64{2, 4, 6, 8},
+		},
+		{
+			`
+			let reduce = fn(arr, initial, f) {
+				let iter = fn(arr, result) {
+					if (len(arr) == 0) {
+						result;
+					} else {
+						iter(rest(arr), f(result, first(arr)));
+					}
+				};
+				iter(arr, initial);
+			};
+			let sum = fn(arr) {
+				reduce(arr, 0, fn(initial, el) { initial + el });
+			};
+			sum([1, 2, 3, 4, 5]);
+			`,
+			15,
+		},
+
+		{`puts("Hello", "World")`, nil},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, obj, int64(expected))
+		case string:
+			errObj, ok := obj.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		case []int64:
+			array, ok := obj.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array. got=%T (%+v)", obj, obj)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong length want %d. got=%d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, e := range array.Elements {
+				if v, ok := e.(*object.Integer); ok && v.Value != expected[i] {
+					t.Errorf("array want %v, but got=%v", array.Inspect(), expected)
+					break
+				}
+			}
+		case nil:
+			if obj != evaluator.NULL {
+				t.Errorf("expected=%v, got=%v", nil, obj)
+			}
+		}
+	}
+}
+
+func testArrayLiterals(t *testing.T, engine Engine) {
+	input := "[1, 2 * 2, 3 + 3];"
+
+	obj := engine.Run(input)
+	result, ok := obj.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", obj, obj)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func testArrayIndexExpressions(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			"[1, 2, 3][0]",
+			1,
+		},
+		{
+			"[1, 2, 3][1]",
+			2,
+		},
+		{
+			"[1, 2, 3][2]",
+			3,
+		},
+		{
+			"let i = 0; [1][i];",
+			1,
+		},
+		{
+			"[1, 2, 3][1 + 1];",
+			3,
+		},
+		{
+			"let myArray = [1, 2, 3]; myArray[2];",
+			3,
+		},
+		{
+			"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];",
+			6,
+		},
+		{
+			"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]",
+			2,
+		},
+		{
+			"[1, 2, 3][3]",
+			nil,
+		},
+		{
+			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, obj, int64(integer))
+		} else {
+			testNullObject(t, obj)
+		}
+	}
+}
+
+func testStringIndexExpressions(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`"abc"[0]`, "a"},
+		{`"abc"[2]`, "c"},
+		{`let s = "abc"; let i = 1; s[i]`, "b"},
+		{`"héllo"[1]`, "é"},
+		{`"日本語"[2]`, "語"},
+		{`"abc"[3]`, nil},
+		{`"abc"[-1]`, "c"},
+		{`"héllo"[-4]`, "é"},
+		{`"abc"[-4]`, nil},
+		{`""[0]`, nil},
+		{`let s = "héllo"; s[len(s) - 1]`, "o"},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		if expected, ok := tt.expected.(string); ok {
+			testStringObject(t, obj, expected)
+		} else {
+			testNullObject(t, obj)
+		}
+	}
+}
+
+func testHashLiterals(t *testing.T, engine Engine) {
+	input := `
+		let two = "two";
+		{
+			"one": 10 - 9,
+			two: 1 + 1,
+			"thr" + "ee": 6 / 2,
+			4: 4,
+			true: 5,
+			false: 6,
+		};
+	`
+
+	obj := engine.Run(input)
+	result, ok := obj.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", obj, obj)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		evaluator.TRUE.HashKey():                   5,
+		evaluator.FALSE.HashKey():                  6,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+}
+
+func testHashOrder(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, 3: 3, true: 4}`, "{b:1, a:2, 3:3, true:4}"},
+		{`let h = {"b": 1, "a": 2}; h["c"] = 3; h["b"] = 4; h`, "{b:4, a:2, c:3}"},
+		{`{"a": 1, "b": 2, "a": 3}`, "{a:3, b:2}"},
+		{`let s = ""; let f = fn(x) { s = s + x; x }; {f("c"): f("1"), f("a"): f("2")}; s`, "c1a2"},
+		{`let s = ""; for (k, v in {"z": 1, "y": 2, "x": 3}) { s = s + k; } s`, "zyx"},
+		{`keys({"z": 1, "y": 2, "x": 3})`, "[z, y, x]"},
+		{`values({"z": 1, "y": 2, "x": 3})`, "[1, 2, 3]"},
+		{`entries({"z": 1, "y": [2]})`, "[[z, 1], [y, [2]]]"},
+		{`keys({})`, "[]"},
+		{`keys([1])`, "ERROR: argument to `keys` must be HASH, got ARRAY"},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		if obj.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+}
+
+func testHashBuiltins(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`has({"a": null}, "a")`, "true"},
+		{`has({"a": false}, "b")`, "false"},
+		{`has({1: 1, true: 2, 1.5: 3, 100000000000000000000: 4}, 100000000000000000000)`, "true"},
+		{`has({1: 1}, true)`, "false"},
+		{`get({"a": 1}, "a")`, "1"},
+		{`get({"a": 1}, "b")`, "null"},
+		{`get({"a": 1}, "b", 0)`, "0"},
+		{`get({"a": null}, "a", 0)`, "null"},
+		{`get({1.5: "x"}, 1.5, "y")`, "x"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; delete(h, "b")`, "2"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; delete(h, "b"); h`, "{a:1, c:3}"},
+		{`let h = {"a": 1}; delete(h, "z"); h`, "{a:1}"},
+		{`let h = {"a": 1, "b": 2}; delete(h, "a"); h["a"] = 3; h`, "{b:2, a:3}"},
+		{`let h = {false: 1}; delete(h, false); len(h)`, "0"},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4})`, "{a:1, b:3, c:4}"},
+		{`merge({"a": 1}, {}, {2: 2})`, "{a:1, 2:2}"},
+		{`let h = {"a": 1}; let m = merge(h); m["b"] = 2; h`, "{a:1}"},
+		{`has({}, [{}])`, "ERROR: unusable as hash key: ARRAY"},
+		{`get({}, fn() {}, 1)`, "ERROR: unusable as hash key: FUNCTION"},
+		{`delete({}, {})`, "ERROR: unusable as hash key: HASH"},
+		{`get({}, 1, 2, 3)`, "ERROR: wrong number of arguments. got=4, want=2 or 3"},
+		{`merge({}, [])`, "ERROR: argument to `merge` must be HASH, got ARRAY"},
+		{`merge()`, "{}"},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		if obj.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+}
+
+func testStructuralEquality(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`[1, 2] == [1, 2]`, true},
+		{`[1, 2] != [1, 2]`, false},
+		{`[1, 2] == [2, 1]`, false},
+		{`[1, 2] == [1, 2, 3]`, false},
+		{`[] == []`, true},
+		{`[1, [2, "a"]] == [1, [2, "a"]]`, true},
+		{`[1] == [1.0]`, true},
+		{`[null] == [null]`, true},
+		{`[1] == 1`, false},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{`{} == {}`, true},
+		{`null == null`, true},
+		{`[1, 2] == tuple(1, 2)`, false},
+		{`tuple(1, [2]) == tuple(1, tuple(2))`, true},
+		{`let f = fn() { 1 }; [f] == [f]`, true},
+		{`[fn() { 1 }] == [fn() { 1 }]`, false},
+		{`let a = [1]; let b = a; b[0] = 2; a == [2]`, true},
+		{`let a = [1, 2]; a[0] = a; let b = [1, 2]; b[0] = b; a == b`, true},
+		{`let a = [1, 2]; a[0] = a; let b = [1, 3]; b[0] = b; a == b`, false},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, engine.Run(tt.input), tt.expected)
+	}
+}
+
+func testSliceExpressions(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = [1, 2, 3, 4, 5]; a[1:3]`, "[2, 3]"},
+		{`let a = [1, 2, 3, 4, 5]; a[:2]`, "[1, 2]"},
+		{`let a = [1, 2, 3, 4, 5]; a[3:]`, "[4, 5]"},
+		{`let a = [1, 2, 3, 4, 5]; a[-2:]`, "[4, 5]"},
+		{`let a = [1, 2, 3, 4, 5]; a[:-2]`, "[1, 2, 3]"},
+		{`let a = [1, 2, 3, 4, 5]; a[::2]`, "[1, 3, 5]"},
+		{`let a = [1, 2, 3, 4, 5]; a[::-1]`, "[5, 4, 3, 2, 1]"},
+		{`let a = [1, 2, 3, 4, 5]; a[4:1:-2]`, "[5, 3]"},
+		{`let a = [1, 2, 3, 4, 5]; a[3:1]`, "[]"},
+		{`let a = [1, 2, 3, 4, 5]; a[-100:100]`, "[1, 2, 3, 4, 5]"},
+		{`let a = [1, 2, 3]; let b = a[:]; b[0] = 9; [a, b]`, "[[1, 2, 3], [9, 2, 3]]"},
+		{`[1, 2][::9223372036854775807]`, "[1]"},
+		{`[1, 2, 3][1:100000000000000000000]`, "[2, 3]"},
+		{`[1, 2, 3][-100000000000000000000:2]`, "[1, 2]"},
+		{`[1, 2, 3][100000000000000000000:]`, "[]"},
+		{`[1, 2, 3][::-100000000000000000000]`, "[3]"},
+		{`"abc"[-100000000000000000000:100000000000000000000]`, "abc"},
+		{`let a = [1, 2, 3]; a[100000000000000000000:] = [4]; a`, "[1, 2, 3, 4]"},
+		{`"héllo"[1:3]`, "él"},
+		{`"hello"[::-1]`, "olleh"},
+		{`"hello"[-3:]`, "llo"},
+		{`tuple(1, 2, 3)[1:]`, "tuple(2, 3)"},
+		{`[1, 2, 3][::0]`, "ERROR: slice step cannot be zero"},
+		{`[1, 2, 3]["a":]`, "ERROR: slice index must be INTEGER, got STRING"},
+		{`[1, 2, 3][:2:1.5]`, "ERROR: slice step must be INTEGER, got FLOAT"},
+		{`5[1:]`, "ERROR: slice operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		if obj.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+}
+
+func testSliceAssign(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = [1, 2, 3, 4, 5]; a[1:3] = [9]; a`, "[1, 9, 4, 5]"},
+		{`let a = [1, 2, 3]; a[:0] = [0, 0]; a`, "[0, 0, 1, 2, 3]"},
+		{`let a = [1, 2, 3]; a[len(a):] = [4, 5]; a`, "[1, 2, 3, 4, 5]"},
+		{`let a = [1, 2, 3]; a[1:] = []; a`, "[1]"},
+		{`let a = [1, 2, 3, 4]; a[::2] = ["x", "y"]; a`, "[x, 2, y, 4]"},
+		{`let a = [1, 2, 3]; a[::-1] = a; a`, "[3, 2, 1]"},
+		{`let a = [1, 2, 3]; let b = a; a[1:2] = tuple(7, 8); b`, "[1, 7, 8, 3]"},
+		{`let a = [1, 2, 3]; a[-1] = 9; a`, "[1, 2, 9]"},
+		{`let a = [1, 2, 3]; a[::2] = [1]`, "ERROR: cannot assign 1 elements to a slice of 2 elements"},
+		{`let a = [1, 2, 3]; a[1:] = 5`, "ERROR: can only assign ARRAY to a slice, got INTEGER"},
+		{`let a = [1, 2, 3]; a[1:] += [5]`, "ERROR: unsupported assign ARRAY[:] += ARRAY"},
+		{`let s = "abc"; s[1:] = "x"`, "ERROR: slice assignment not supported: STRING"},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		if obj.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+}
+
+func testCompositeHashKeys(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let cache = {}; cache[[1, 2]] = "a"; cache[[1, 2]]`, "a"},
+		{`let cache = {}; cache[[1, 2]] = "a"; cache[[2, 1]]`, "null"},
+		{`let k = [1, 2]; let h = {k: "a"}; k[0] = 5; [h[[1, 2]], h[k]]`, "[a, null]"},
+		{`let h = {[1, [2, "x"]]: 1}; h[[1, [2, "x"]]]`, "1"},
+		{`let h = {tuple(1, 2): 1}; h[[1, 2]]`, "1"},
+		{`{[1, 2]: 1}`, "{tuple(1, 2):1}"},
+		{`keys({[1, true]: 1})[0]`, "tuple(1, true)"},
+		{`let h = {}; h[[1]] = 1; h[[1]] += 1; h`, "{tuple(1):2}"},
+		{`has({[1]: null}, [1])`, "true"},
+		{`let t = tuple(1, [2, 3]); [len(t), t[1][0], t[5]]`, "[2, 2, null]"},
+		{`let s = 0; for (x in tuple(1, 2, 3)) { s += x; } s`, "6"},
+		{`let memo = {}; let paths = fn(i, j) { if (i == 0 || j == 0) { return 1; } if (has(memo, [i, j])) { return memo[[i, j]]; } let r = paths(i - 1, j) + paths(i, j - 1); memo[[i, j]] = r; r }; paths(16, 16)`, "601080390"},
+		{`let h = {1: "a"}; [h[1.0], h[1.5]]`, "[a, null]"},
+		{`let h = {}; h[2] = 1; h[2.0] += 1; [len(h), h[2]]`, "[1, 2]"},
+		{`{[1, 2]: "a"}[[1.0, 2]]`, "a"},
+		{`{[1, {}]: 1}`, "ERROR: unusable as hash key: ARRAY"},
+		{`tuple(1, {})`, "ERROR: unusable as tuple element: HASH"},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		if obj.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+}
+
+func testHashIndexExpressions(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{
+			`{"foo": 5}["foo"]`,
+			5,
+		},
+		{
+			`{"foo": 5}["bar"]`,
+			nil,
+		},
+		{
+			`let key = "foo"; {"foo": 5}[key]`,
+			5,
+		},
+		{
+			`{}["foo"]`,
+			nil,
+		},
+		{
+			`{5: 5}[5]`,
+			5,
+		},
+		{
+			`{true: 5}[true]`,
+			5,
+		},
+		{
+			`{false: 5}[false]`,
+			5,
+		},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, obj, int64(integer))
+		} else {
+			testNullObject(t, obj)
+		}
+	}
+}
+
+func testWhileStatement(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{
+			`
+				let x = 0;
+				while (x < 0) {
+					x = x + 1;
+				}
+				x;
+			`,
+			0,
+		},
+		{
+			`
+				let x = 0;
+				while (x < 5) {
+					x = x + 1;
+				}
+				x;
+			`,
+			5,
+		},
+		{
+			`
+				let x = 0;
+				while (x < 5) {
+					x = x + 1;
+					break;
+				}
+				x;
+			`,
+			1,
+		},
+		{
+			`
+				let x = 0;
+				let y = 0;
+				while (x < 5) {
+					x = x + 1;
+					y -= 1;
+					continue;
+					y += 1;
+				}
+				y;
+			`,
+			-5,
+		},
+		{
+			`
+				let x = 0;
+				fn() {
+					while (true) {
+						x = x + 1;
+						if (x > 4) {
+							return null;
+						}
+					}
+				}()
+				x;
+			`,
+			5,
+		},
+		{
+			`
+				let x = 0;
+				while (true) {
+					x = x + 1;
+					if (x >= 5) {
+						break;
+					}
+				}
+				x;
+			`,
+			5,
+		},
+		{
+			`
+				let x = 0;
+				let y = 0;
+				while (x < 5) {
+					x += 1;
+					if (x > 3) {
+						continue
+					}
+					y += 1;
+				}
+				y;
+			`,
+			3,
+		},
+		{
+			`
+				let x = 0;
+				let y = 0;
+				while (x < 5) {
+					x += 1;
+					if (true) {
+						continue
+					}
+					y += 1;
+				}
+				y;
+			`,
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, obj, int64(integer))
+		} else {
+			testNullObject(t, obj)
+		}
+	}
+}
+
+func testForStatement(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"let sum = 0; for (let i = 0; i < 5; i += 1) { sum += i; } sum;", 10},
+		{"let sum = 0; for (let i = 0; i < 0; i += 1) { sum += i; } sum;", 0},
+		{"let i = 0; for (i = 10; i < 15; i += 1) {} i;", 15},
+		{"let n = 0; for (;;) { n += 1; if (n == 3) { break; } } n;", 3},
+		{
+			`
+				let sum = 0;
+				for (let i = 0; i < 10; i += 1) {
+					if (i % 2 == 0) {
+						continue;
+					}
+					sum += i;
+				}
+				sum;
+			`,
+			25,
+		},
+		{
+			`
+				let sum = 0;
+				for (let i = 0; i < 3; i += 1) {
+					for (let j = 0; j < 3; j += 1) {
+						if (j > i) {
+							break;
+						}
+						sum += 1;
+					}
+				}
+				sum;
+			`,
+			6,
+		},
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x; } sum;", 6},
+		{"let sum = 0; for (i, x in [5, 6, 7]) { sum += i; } sum;", 3},
+		{"let n = 0; for (c in \"héllo\") { n += 1; } n;", 5},
+		{"let s = \"\"; for (i, c in \"abc\") { s = c + s; } len(s);", 3},
+		{"let sum = 0; for (v in []) { sum += 1; } sum;", 0},
+		{"let sum = 0; for (k in {1: 10, 2: 20}) { sum += k; } sum;", 3},
+		{"let sum = 0; for (k, v in {1: 10, 2: 20}) { sum += v; } sum;", 30},
+		{
+			`
+				let sum = 0;
+				for (x in [1, 2, 3, 4, 5]) {
+					if (x == 2) {
+						continue;
+					}
+					if (x == 4) {
+						break;
+					}
+					sum += x;
+				}
+				sum;
+			`,
+			4,
+		},
+		{
+			`
+				let find = fn(arr, target) {
+					for (i, x in arr) {
+						if (x == target) {
+							return i;
+						}
+					}
+					return -1;
+				};
+				find([3, 4, 5], 5) * 10 + find([], 1);
+			`,
+			19,
+		},
+		{
+			`
+				let find = fn(grid, target) {
+					for (let i = 0; i < len(grid); i += 1) {
+						for (j, x in grid[i]) {
+							let k = 0;
+							while (k < 1) {
+								if (x == target) {
+									return i * 10 + j;
+								}
+								k += 1;
+							}
+						}
+					}
+					return -1;
+				};
+				find([[1, 2], [3, 4]], 4) * 100 + find([[1]], 5);
+			`,
+			1099,
+		},
+		{"for (x in [1]) {}", nil},
+		{"for (x in 5) {}", "not iterable: INTEGER"},
+		{"for (let i = 0; i < 1; i += true) {}", "unsupported assign INTEGER += BOOLEAN"},
+		{"for (x in [1]) { return x; }", "return statement unsupported if while-loop not inside a function"},
+		{"for (x in [1]) { for (y in [2]) { return y; } }", "return statement unsupported if while-loop not inside a function"},
+	}
+
+	for _, tt := range tests {
+		obj := engine.Run(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, obj, int64(expected))
+		case string:
+			errObj, ok := obj.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		default:
+			testNullObject(t, obj)
+		}
+	}
+}
+
+func testLargePrograms(t *testing.T, engine Engine) {
+	elements := strings.Repeat("1, ", 2999) + "1"
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"len([" + elements + "])", 3000},
+		{"let f = fn(n) { if (n == 0) { return 0; } 1 + f(n - 1) }; f(5000)", 5000},
+		{"let sum = fn(...xs) { reduce(xs, fn(a, b) { a + b }, 0) }; sum(...[" + elements + "])", 3000},
+		{`
+			let counter = fn() {
+				let n = 0;
+				let inc = fn() { n += 1 };
+				let deep = fn(d) { if (d == 0) { inc(); return n; } deep(d - 1) };
+				deep(3000) + n
+			};
+			counter()
+		`, 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, engine.Run(tt.input), tt.expected)
+	}
+}
@@ -0,0 +1,228 @@
+// Package enginetest holds the test cases of the language. Every engine runs
+// the same cases, so the evaluator and the vm cannot drift apart
+package enginetest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/labasubagia/interpreter/ast"
+	"github.com/labasubagia/interpreter/evaluator"
+	"github.com/labasubagia/interpreter/lexer"
+	"github.com/labasubagia/interpreter/object"
+	"github.com/labasubagia/interpreter/parser"
+)
+
+// Engine runs programs, errors of any stage are returned as *object.Error
+type Engine struct {
+	Run func(input string) object.Object
+
+	// RunFile runs input as file, importing modules from modules
+	RunFile func(input, file string, modules *evaluator.Modules) object.Object
+
+	// RunContext runs input with limits, stopping when ctx is done
+	RunContext func(ctx context.Context, input string, limits evaluator.Limits) object.Object
+}
+
+var cases = []struct {
+	name string
+	fn   func(t *testing.T, engine Engine)
+}{
+	{"IntegerExpression", testIntegerExpression},
+	{"FloatExpression", testFloatExpression},
+	{"BigIntegerExpression", testBigIntegerExpression},
+	{"LogicalExpression", testLogicalExpression},
+	{"BooleanExpression", testBooleanExpression},
+	{"BangOperator", testBangOperator},
+	{"IfElseExpressions", testIfElseExpressions},
+	{"ReturnStatements", testReturnStatements},
+	{"ErrorHandling", testErrorHandling},
+	{"ErrorStackTrace", testErrorStackTrace},
+	{"CallbackStackTrace", testCallbackStackTrace},
+	{"RethrowStackTrace", testRethrowStackTrace},
+	{"ExecutionLimits", testExecutionLimits},
+	{"ExecutionTimeout", testExecutionTimeout},
+	{"MaxDepth", testMaxDepth},
+	{"LetStatements", testLetStatements},
+	{"AssignExpressions", testAssignExpressions},
+	{"FunctionApplication", testFunctionApplication},
+	{"FunctionWithoutValue", testFunctionWithoutValue},
+	{"RestAndSpread", testRestAndSpread},
+	{"TryCatch", testTryCatch},
+	{"Import", testImport},
+	{"ImportStackTrace", testImportStackTrace},
+	{"HigherOrderBuiltins", testHigherOrderBuiltins},
+	{"StringBuiltins", testStringBuiltins},
+	{"Closures", testClosures},
+	{"StringLiteral", testStringLiteral},
+	{"StringConcatenation", testStringConcatenation},
+	{"StringInterpolation", testStringInterpolation},
+	{"BuiltinFunctions", testBuiltinFunctions},
+	{"ArrayLiterals", testArrayLiterals},
+	{"ArrayIndexExpressions", testArrayIndexExpressions},
+	{"StringIndexExpressions", testStringIndexExpressions},
+	{"HashLiterals", testHashLiterals},
+	{"HashOrder", testHashOrder},
+	{"HashBuiltins", testHashBuiltins},
+	{"StructuralEquality", testStructuralEquality},
+	{"SliceExpressions", testSliceExpressions},
+	{"SliceAssign", testSliceAssign},
+	{"CompositeHashKeys", testCompositeHashKeys},
+	{"HashIndexExpressions", testHashIndexExpressions},
+	{"WhileStatement", testWhileStatement},
+	{"ForStatement", testForStatement},
+	{"LargePrograms", testLargePrograms},
+}
+
+// Parse parses input for an engine, the first syntax error is returned as *object.Error
+func Parse(input string) (*ast.Program, *object.Error) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, &object.Error{Message: errs[0].Message, Position: errs[0].Span.Start}
+	}
+	return program, nil
+}
+
+// Run runs every case against engine as a subtest
+func Run(t *testing.T, engine Engine) {
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) { c.fn(t, engine) })
+	}
+}
+
+func testExecutionLimits(t *testing.T, engine Engine) {
+	tests := []struct {
+		input  string
+		limits evaluator.Limits
+	}{
+		{"while (true) {}", evaluator.Limits{MaxSteps: 1000}},
+		{"let f = fn(x) { f(x + 1) }; f(0)", evaluator.Limits{MaxDepth: 100}},
+		{"let f = fn(x) { f(x + 1) }; f(0)", evaluator.Limits{}},
+		{"let arr = []; while (true) { arr = push(arr, [1, 2, 3]) }", evaluator.Limits{MaxAllocations: 1000}},
+		{"let arr = []; while (true) { arr = push(arr, 1) }", evaluator.Limits{MaxAllocations: 1000}},
+		{"try { while (true) {} } catch (e) { 1 }", evaluator.Limits{MaxSteps: 1000}},
+	}
+
+	for _, tt := range tests {
+		obj := engine.RunContext(context.Background(), tt.input, tt.limits)
+
+		errObj, ok := obj.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, obj, obj)
+			continue
+		}
+		if errObj.Message != evaluator.LimitExceeded {
+			t.Errorf("wrong error message. expected=%q, got=%q", evaluator.LimitExceeded, errObj.Message)
+		}
+	}
+}
+
+// testMaxDepth checks that both engines stop at the same depth, f(n) nests n + 1 calls
+func testMaxDepth(t *testing.T, engine Engine) {
+	input := "let f = fn(n) { if (n == 0) { return 0; } 1 + f(n - 1) }; f(%d)"
+	limits := evaluator.Limits{MaxDepth: 2000}
+
+	obj := engine.RunContext(context.Background(), fmt.Sprintf(input, 1999), limits)
+	testIntegerObject(t, obj, 1999)
+
+	obj = engine.RunContext(context.Background(), fmt.Sprintf(input, 2000), limits)
+	errObj, ok := obj.(*object.Error)
+	if !ok || errObj.Message != evaluator.LimitExceeded || errObj.Kind != object.LIMIT_ERROR {
+		t.Fatalf("expected LimitError %q. got=%T(%+v)", evaluator.LimitExceeded, obj, obj)
+	}
+
+	// the recursive calls are collapsed into one line
+	expectedTraceback := `Traceback (most recent call last):
+  line 1, column 59, in <main>
+  line 1, column 47, in f
+  line 1, column 47, in f
+  line 1, column 47, in f
+  [Previous line repeated 1997 more times]
+ERROR: execution limit exceeded`
+	if errObj.Traceback() != expectedTraceback {
+		t.Errorf("wrong traceback. expected=%q, got=%q", expectedTraceback, errObj.Traceback())
+	}
+}
+
+func testExecutionTimeout(t *testing.T, engine Engine) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	obj := engine.RunContext(ctx, "while (true) {}", evaluator.Limits{})
+
+	errObj, ok := obj.(*object.Error)
+	if !ok || errObj.Message != evaluator.LimitExceeded {
+		t.Errorf("expected %q error. got=%T(%+v)", evaluator.LimitExceeded, obj, obj)
+	}
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != evaluator.NULL {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%v, want=%v", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
+		return false
+	}
+
+	return true
+
+}
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%q, want=%q", result.Value, expected)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,161 @@
+// Package interpreter embeds the language in Go programs
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/labasubagia/interpreter/evaluator"
+	"github.com/labasubagia/interpreter/lexer"
+	"github.com/labasubagia/interpreter/object"
+	"github.com/labasubagia/interpreter/parser"
+)
+
+// ParseErrors is returned when the source has syntax errors
+type ParseErrors []*parser.ParseError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+type Option func(*Interpreter)
+
+func WithStdout(w io.Writer) Option {
+	return func(i *Interpreter) { i.ctx.Stdout = w }
+}
+
+func WithStderr(w io.Writer) Option {
+	return func(i *Interpreter) { i.ctx.Stderr = w }
+}
+
+func WithStdin(r io.Reader) Option {
+	return func(i *Interpreter) { i.ctx.Stdin = r }
+}
+
+// WithLimits bounds every Run and Call, see evaluator.Limits
+func WithLimits(limits evaluator.Limits) Option {
+	return func(i *Interpreter) { i.eval.SetLimits(limits) }
+}
+
+// WithSearchPath sets the directories searched by imports not found next to the importing file
+func WithSearchPath(dirs ...string) Option {
+	return func(i *Interpreter) { i.eval.SetModules(evaluator.NewModules(dirs...)) }
+}
+
+// Interpreter runs scripts sharing the same globals.
+// It is not safe for concurrent use, create one per goroutine instead
+type Interpreter struct {
+	env      *object.Environment
+	eval     *evaluator.Evaluator
+	builtins evaluator.Builtins
+	ctx      *object.ExecContext
+}
+
+func New(opts ...Option) *Interpreter {
+	builtins := evaluator.NewBuiltins()
+	i := &Interpreter{
+		env:      object.NewEnvironment(),
+		eval:     evaluator.NewWithBuiltins(builtins),
+		builtins: builtins,
+		ctx:      object.NewExecContext(),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	i.eval.SetContext(i.ctx)
+
+	return i
+}
+
+// Register makes a native function available to the scripts of this interpreter
+func (i *Interpreter) Register(builtin *object.Builtin) error {
+	return i.builtins.Register(builtin)
+}
+
+// Builtin returns the builtin called name, e.g. to show its documentation
+func (i *Interpreter) Builtin(name string) (*object.Builtin, bool) {
+	builtin, ok := i.builtins[name]
+	return builtin, ok
+}
+
+// Builtins returns the sorted names of the available builtins
+func (i *Interpreter) Builtins() []string {
+	return i.builtins.Names()
+}
+
+// Run evaluates src and returns the value of its last statement.
+// Errors are either ParseErrors or *object.Error
+func (i *Interpreter) Run(src string) (object.Object, error) {
+	return i.RunContext(context.Background(), src)
+}
+
+// RunContext is Run stopping with an "execution limit exceeded" error when ctx is done
+func (i *Interpreter) RunContext(ctx context.Context, src string) (obj object.Object, err error) {
+	defer recoverError(&err)
+
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, ParseErrors(p.Errors())
+	}
+
+	return result(i.eval.EvalContext(ctx, program, i.env, evaluator.ScopeNone))
+}
+
+// RunFile runs the script at path, its imports are resolved relative to it
+func (i *Interpreter) RunFile(path string) (object.Object, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	i.eval.SetFile(path)
+	defer i.eval.SetFile("")
+
+	return i.Run(string(b))
+}
+
+// Call calls the global function fnName, e.g. one defined by a previous Run
+func (i *Interpreter) Call(fnName string, args ...object.Object) (obj object.Object, err error) {
+	defer recoverError(&err)
+
+	fn, ok := i.env.Get(fnName)
+	if !ok {
+		return nil, fmt.Errorf("identifier not found: %s", fnName)
+	}
+	return result(i.eval.ApplyContext(context.Background(), fn, args))
+}
+
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+func (i *Interpreter) Set(name string, val object.Object) {
+	i.env.Set(name, val)
+}
+
+func result(obj object.Object) (object.Object, error) {
+	if err, ok := obj.(*object.Error); ok {
+		return nil, err
+	}
+	if obj == nil {
+		return evaluator.NULL, nil
+	}
+	return obj, nil
+}
+
+// recoverError turns a panic, e.g. in a registered builtin, into an *object.Error
+// so a script cannot crash the program running it
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		*err = object.NewError(object.GENERIC_ERROR, "internal error: %v", r)
+	}
+}
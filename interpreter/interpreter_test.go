@@ -0,0 +1,305 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/labasubagia/interpreter/evaluator"
+	"github.com/labasubagia/interpreter/object"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2", "3"},
+		{`"a" + "b"`, "ab"},
+		{"let x = 5;", "null"},
+		{"[1, 2][1]", "2"},
+		{"fn() {}()", "null"},
+		{"map([1], fn(x) {})", "[null]"},
+	}
+
+	for _, tt := range tests {
+		result, err := New().Run(tt.input)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", tt.input, err)
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%s, got=%s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestRunKeepsGlobals(t *testing.T) {
+	interp := New()
+
+	if _, err := interp.Run("let add = fn(a, b) { a + b }; let x = 10;"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := interp.Run("add(x, 5)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Inspect() != "15" {
+		t.Errorf("wrong result. want=15, got=%s", result.Inspect())
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	_, err := New().Run("let = 5;")
+	var parseErrs ParseErrors
+	if !errors.As(err, &parseErrs) {
+		t.Fatalf("expected ParseErrors. got=%T (%v)", err, err)
+	}
+
+	_, err = New().Run("1 + true")
+	var runtimeErr *object.Error
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected *object.Error. got=%T (%v)", err, err)
+	}
+	if runtimeErr.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong error message. got=%q", runtimeErr.Message)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.newpl")
+	if err := os.WriteFile(path, []byte("let x = 2; x * 21"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := New().RunFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Inspect() != "42" {
+		t.Errorf("wrong result. want=42, got=%s", result.Inspect())
+	}
+
+	if _, err := New().RunFile(filepath.Join(t.TempDir(), "missing.newpl")); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+}
+
+func TestRunFileImport(t *testing.T) {
+	dir, libDir := t.TempDir(), t.TempDir()
+	files := map[string]string{
+		filepath.Join(dir, "main.newpl"):      `let m = import "math.newpl"; let s = import "shared.newpl"; m["double"](s["base"])`,
+		filepath.Join(dir, "math.newpl"):      `export let double = fn(x) { x * 2 };`,
+		filepath.Join(libDir, "shared.newpl"): `export let base = 21;`,
+	}
+	for path, src := range files {
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := New(WithSearchPath(libDir)).RunFile(filepath.Join(dir, "main.newpl"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Inspect() != "42" {
+		t.Errorf("wrong result. want=42, got=%s", result.Inspect())
+	}
+
+	if _, err := New().RunFile(filepath.Join(dir, "main.newpl")); err == nil || err.Error() != "module not found: shared.newpl" {
+		t.Errorf("expected module not found error. got=%v", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	interp := New()
+	if _, err := interp.Run("let double = fn(x) { x * 2 };"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := interp.Call("double", &object.Integer{Value: 21})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Inspect() != "42" {
+		t.Errorf("wrong result. want=42, got=%s", result.Inspect())
+	}
+
+	tests := []struct {
+		fnName   string
+		args     []object.Object
+		expected string
+	}{
+		{"missing", nil, "identifier not found: missing"},
+		{"double", nil, "wrong number of arguments: want=1, got=0"},
+		{"double", []object.Object{&object.String{Value: "a"}}, "type mismatch: STRING * INTEGER"},
+	}
+	for _, tt := range tests {
+		_, err := interp.Call(tt.fnName, tt.args...)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%v", tt.expected, err)
+		}
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	interp := New()
+	interp.Set("limit", &object.Integer{Value: 3})
+
+	if _, err := interp.Run("let doubled = limit * 2;"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	val, ok := interp.Get("doubled")
+	if !ok {
+		t.Fatalf("doubled is not defined")
+	}
+	if val.Inspect() != "6" {
+		t.Errorf("wrong value. want=6, got=%s", val.Inspect())
+	}
+
+	if _, ok := interp.Get("missing"); ok {
+		t.Errorf("missing should not be defined")
+	}
+}
+
+func TestStdout(t *testing.T) {
+	var out bytes.Buffer
+	interp := New(WithStdout(&out))
+
+	if _, err := interp.Run(`puts("hello", 1, [2]); puts("world")`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := "hello 1 [2]\nworld\n"
+	if out.String() != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestRegister(t *testing.T) {
+	interp := New()
+	err := interp.Register(&object.Builtin{
+		Name:   "greet",
+		Doc:    "greet(name) returns a greeting for name",
+		Params: []object.ObjectType{object.STRING_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			return &object.String{Value: "hello " + args[0].(*object.String).Value}
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`greet("bob")`, "hello bob"},
+		{`greet(1)`, "argument to `greet` must be STRING, got INTEGER"},
+		{`greet()`, "wrong number of arguments. got=0, want=1"},
+		{`let greet = fn(x) { x }; greet(2)`, "2"},
+	}
+	for _, tt := range tests {
+		result, err := interp.Run(tt.input)
+		if err != nil {
+			result = &object.String{Value: err.Error()}
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, result.Inspect())
+		}
+	}
+
+	builtin, ok := interp.Builtin("greet")
+	if !ok || builtin.Doc != "greet(name) returns a greeting for name" {
+		t.Errorf("wrong builtin greet. got=%+v", builtin)
+	}
+
+	// builtins are per interpreter
+	if _, err := New().Run(`greet("bob")`); err == nil || err.Error() != "identifier not found: greet" {
+		t.Errorf("greet should not be defined in other interpreters. got=%v", err)
+	}
+
+	if err := interp.Register(&object.Builtin{Name: "nothing"}); err == nil {
+		t.Errorf("expected error registering builtin without function")
+	}
+}
+
+func TestRegisterTypedError(t *testing.T) {
+	interp := New()
+	err := interp.Register(&object.Builtin{
+		Name:   "positive",
+		Params: []object.ObjectType{object.INTEGER_OBJ},
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			if n := args[0].(*object.Integer).Value; n <= 0 {
+				return object.NewError(object.VALUE_ERROR, "%d is not positive", n)
+			}
+			return args[0]
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err := interp.Run(`let r = ""; try { positive(-1); } catch (e) { r = e["type"] + ": " + e["message"]; } r`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "ValueError: -1 is not positive"; result.Inspect() != want {
+		t.Errorf("wrong result. want=%q, got=%q", want, result.Inspect())
+	}
+}
+
+func TestRecoverPanic(t *testing.T) {
+	interp := New()
+	err := interp.Register(&object.Builtin{
+		Name: "crash",
+		Fn: func(ctx *object.ExecContext, args ...object.Object) object.Object {
+			panic("boom")
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := interp.Run("let f = fn() { crash() };"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := "internal error: boom"
+	if _, err := interp.Run("crash()"); err == nil || err.Error() != want {
+		t.Errorf("expected %q error from Run. got=%v", want, err)
+	}
+	if _, err := interp.Call("f"); err == nil || err.Error() != want {
+		t.Errorf("expected %q error from Call. got=%v", want, err)
+	}
+
+	// the interpreter is still usable after a panic
+	result, err := interp.Run("1 + 2")
+	if err != nil || result.Inspect() != "3" {
+		t.Errorf("wrong result after panic. got=%v, %v", result, err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	interp := New(WithLimits(evaluator.Limits{MaxSteps: 1000}))
+
+	_, err := interp.Run("while (true) {}")
+	if err == nil || err.Error() != evaluator.LimitExceeded {
+		t.Errorf("expected %q error. got=%v", evaluator.LimitExceeded, err)
+	}
+
+	// the budget is per run
+	if _, err := interp.Run("let x = 1;"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = New().RunContext(ctx, "while (true) {}")
+	if err == nil || err.Error() != evaluator.LimitExceeded {
+		t.Errorf("expected %q error. got=%v", evaluator.LimitExceeded, err)
+	}
+}
@@ -1,6 +1,12 @@
 package lexer
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
 	"github.com/labasubagia/interpreter/token"
 )
 
@@ -9,10 +15,20 @@ type Lexer struct {
 	position     int // current position in input (point to current char)
 	readPosition int // current reading position in input (after current char)
 	ch           byte
+	line         int // line of current char
+	column       int // column of current char
+
+	interpolations []interpolation // the ${ being read, innermost last
+}
+
+// interpolation is a ${ of a string, the string continues after its closing '}'
+type interpolation struct {
+	braces    int  // number of unclosed '{' inside the expression
+	multiline bool // the string is triple quoted
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
@@ -21,6 +37,7 @@ func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipUnused()
+	start := l.pos()
 
 	switch l.ch {
 	case '=':
@@ -86,6 +103,32 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.MODULO, l.ch)
 		}
+	case '&':
+		if l.peekChar() == '&' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.AND, Literal: literal}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.OR, Literal: literal}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '.':
+		if l.peekChar() == '.' && l.peekCharAt(2) == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '<':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -113,12 +156,23 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1].braces += 1
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
+		if n := len(l.interpolations); n > 0 {
+			if l.interpolations[n-1].braces == 0 {
+				return l.readInterpolationEnd(start)
+			}
+			l.interpolations[n-1].braces -= 1
+		}
 		tok = newToken(token.RBRACE, l.ch)
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		return l.readString(start)
+	case '`':
+		value, err := l.readRawString()
+		return l.stringToken(token.STRING, value, err, start)
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
@@ -128,45 +182,193 @@ func (l *Lexer) NextToken() token.Token {
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
+		tok.Span = token.Span{Start: start, End: start}
+		return tok
 	default:
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Span = token.Span{Start: start, End: l.pos()}
 			return tok
 		}
 
 		if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Type, tok.Literal = l.readNumber()
+			tok.Span = token.Span{Start: start, End: l.pos()}
 			return tok
 		}
 
 		tok = newToken(token.ILLEGAL, l.ch)
 	}
 	l.readChar()
+	tok.Span = token.Span{Start: start, End: l.pos()}
 	return tok
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
-	for {
+func (l *Lexer) stringToken(tokenType token.TokenType, value string, err error, start token.Position) token.Token {
+	span := token.Span{Start: start, End: l.pos()}
+	if err != nil {
+		return token.Token{Type: token.ERROR, Literal: err.Error(), Span: span}
+	}
+	return token.Token{Type: tokenType, Literal: value, Span: span}
+}
+
+// readString reads a string between double quotes, or between triple double quotes when it may span lines.
+// A string with ${ is read up to it, as STRING_START, the parser then reads the expression
+func (l *Lexer) readString(start token.Position) token.Token {
+	multiline := l.peekChar() == '"' && l.peekCharAt(2) == '"'
+	if multiline {
+		l.readChar()
+		l.readChar()
 		l.readChar()
+		// a newline right after the opening quotes is not part of the string
+		if l.ch == '\n' {
+			l.readChar()
+		}
+	} else {
+		l.readChar()
+	}
+
+	value, open, err := l.readStringPart(multiline)
+	if open {
+		return l.stringToken(token.STRING_START, value, err, start)
+	}
+	return l.stringToken(token.STRING, value, err, start)
+}
+
+// readInterpolationEnd reads the string after the '}' closing a ${, as STRING_MIDDLE when another ${ follows
+func (l *Lexer) readInterpolationEnd(start token.Position) token.Token {
+	n := len(l.interpolations)
+	multiline := l.interpolations[n-1].multiline
+	l.interpolations = l.interpolations[:n-1]
+	l.readChar()
+
+	value, open, err := l.readStringPart(multiline)
+	if open {
+		return l.stringToken(token.STRING_MIDDLE, value, err, start)
+	}
+	return l.stringToken(token.STRING_END, value, err, start)
+}
 
-		isDoubleQuote := l.input[l.position] == '\\' && l.input[l.readPosition] == '"'
-		isEnter := l.input[l.position] == '\\' && l.input[l.readPosition] == 'n'
-		isTab := l.input[l.position] == '\\' && l.input[l.readPosition] == 't'
-		if isDoubleQuote || isEnter || isTab {
+// readStringPart decodes a string up to its closing quotes or up to a ${, open reports the latter
+func (l *Lexer) readStringPart(multiline bool) (value string, open bool, err error) {
+	var out strings.Builder
+
+	for !l.isStringEnd(multiline) {
+		if l.ch == 0 || (l.ch == '\n' && !multiline) {
+			return "", false, errors.New("unterminated string")
+		}
+		if l.ch == '$' && l.peekChar() == '{' {
 			l.readChar()
 			l.readChar()
+			l.interpolations = append(l.interpolations, interpolation{multiline: multiline})
+			return out.String(), true, err
 		}
-		if l.ch == '"' || l.ch == 0 {
-			break
+		if l.ch == '\\' {
+			if escErr := l.readEscape(&out); err == nil {
+				err = escErr
+			}
+			continue
 		}
+		out.WriteByte(l.ch)
+		l.readChar()
+	}
+
+	l.readChar()
+	if multiline {
+		l.readChar()
+		l.readChar()
+	}
+	return out.String(), false, err
+}
+
+func (l *Lexer) isStringEnd(multiline bool) bool {
+	if multiline {
+		return l.ch == '"' && l.peekChar() == '"' && l.peekCharAt(2) == '"'
+	}
+	return l.ch == '"'
+}
+
+// readRawString reads a string between backticks, it may span lines and has no escape sequences
+func (l *Lexer) readRawString() (string, error) {
+	l.readChar()
+	position := l.position
+	for l.ch != '`' {
+		if l.ch == 0 {
+			return "", errors.New("unterminated raw string")
+		}
+		l.readChar()
+	}
+	value := l.input[position:l.position]
+	l.readChar()
+	return value, nil
+}
+
+// readEscape decodes the escape sequence starting at the current backslash into out
+func (l *Lexer) readEscape(out *strings.Builder) error {
+	l.readChar()
+	switch l.ch {
+	case 0, '\n':
+		return nil // reported as an unterminated string
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case 'r':
+		out.WriteByte('\r')
+	case '\\', '"', '$':
+		out.WriteByte(l.ch)
+	case 'x':
+		l.readChar()
+		digits := l.readHexDigits(2)
+		if len(digits) != 2 {
+			return fmt.Errorf("invalid escape sequence \\x%s, want 2 hex digits", digits)
+		}
+		value, _ := strconv.ParseUint(digits, 16, 8)
+		out.WriteByte(byte(value))
+		return nil
+	case 'u':
+		if l.peekChar() != '{' {
+			return errors.New("invalid escape sequence \\u, want \\u{hex digits}")
+		}
+		l.readChar()
+		l.readChar()
+		digits := l.readHexDigits(6)
+		if l.ch != '}' || digits == "" {
+			return fmt.Errorf("invalid escape sequence \\u{%s, want \\u{hex digits}", digits)
+		}
+		l.readChar()
+		value, _ := strconv.ParseUint(digits, 16, 32)
+		if !utf8.ValidRune(rune(value)) {
+			return fmt.Errorf("invalid escape sequence \\u{%s}, not a unicode character", digits)
+		}
+		out.WriteRune(rune(value))
+		return nil
+	default:
+		ch := l.ch
+		l.readChar()
+		return fmt.Errorf("unknown escape sequence \\%c", ch)
+	}
+	l.readChar()
+	return nil
+}
+
+// readHexDigits reads up to n hex digits
+func (l *Lexer) readHexDigits(n int) string {
+	position := l.position
+	for l.position-position < n && isHexDigit(l.ch) {
+		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -176,6 +378,10 @@ func (l *Lexer) readChar() {
 	l.readPosition += 1
 }
 
+func (l *Lexer) pos() token.Position {
+	return token.Position{Offset: l.position, Line: l.line, Column: l.column}
+}
+
 func (l *Lexer) peekChar() byte {
 	if l.readPosition >= len(l.input) {
 		return 0
@@ -184,6 +390,14 @@ func (l *Lexer) peekChar() byte {
 	}
 }
 
+// peekCharAt looks n chars ahead of the current char
+func (l *Lexer) peekCharAt(n int) byte {
+	if l.position+n >= len(l.input) {
+		return 0
+	}
+	return l.input[l.position+n]
+}
+
 func (l *Lexer) skipUnused() {
 	for isWhitespace(l.ch) || l.isComment() {
 		l.readChar()
@@ -209,12 +423,37 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer or a float like 3.14, 1e-9 or 2.5E+3
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	position := l.position
+	tokenType := token.TokenType(token.INT)
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.peekChar()
+		if isDigit(next) || ((next == '+' || next == '-') && isDigit(l.peekCharAt(2))) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return tokenType, l.input[position:l.position]
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
 }
 
 func newToken(tokenType token.TokenType, ch byte) token.Token {
@@ -229,6 +468,10 @@ func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+func isHexDigit(ch byte) bool {
+	return isDigit(ch) || ('a' <= ch && ch <= 'f') || ('A' <= ch && ch <= 'F')
+}
+
 func isWhitespace(ch byte) bool {
 	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
 }
@@ -158,9 +158,9 @@ func TestNextToken(t *testing.T) {
 
 		{token.STRING, "foobar"},
 		{token.STRING, "foo bar"},
-		{token.STRING, `hello \"world\"`},
-		{token.STRING, `hello\n world`},
-		{token.STRING, `hello\t\t\tworld`},
+		{token.STRING, `hello "world"`},
+		{token.STRING, "hello\n world"},
+		{token.STRING, "hello\t\t\tworld"},
 
 		{token.LBRACKET, "["},
 		{token.INT, "1"},
@@ -283,3 +283,207 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPosition(t *testing.T) {
+	input := "let x = 5;\n# comment\n  puts(\"a b\");"
+
+	tests := []struct {
+		expectedType  token.TokenType
+		expectedStart token.Position
+		expectedEnd   token.Position
+	}{
+		{token.LET, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{token.IDENT, token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{token.ASSIGN, token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{token.INT, token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 9, Line: 1, Column: 10}},
+		{token.SEMICOLON, token.Position{Offset: 9, Line: 1, Column: 10}, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{token.IDENT, token.Position{Offset: 23, Line: 3, Column: 3}, token.Position{Offset: 27, Line: 3, Column: 7}},
+		{token.LPAREN, token.Position{Offset: 27, Line: 3, Column: 7}, token.Position{Offset: 28, Line: 3, Column: 8}},
+		{token.STRING, token.Position{Offset: 28, Line: 3, Column: 8}, token.Position{Offset: 33, Line: 3, Column: 13}},
+		{token.RPAREN, token.Position{Offset: 33, Line: 3, Column: 13}, token.Position{Offset: 34, Line: 3, Column: 14}},
+		{token.SEMICOLON, token.Position{Offset: 34, Line: 3, Column: 14}, token.Position{Offset: 35, Line: 3, Column: 15}},
+		{token.EOF, token.Position{Offset: 35, Line: 3, Column: 15}, token.Position{Offset: 35, Line: 3, Column: 15}},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - token type wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Span.Start != tt.expectedStart {
+			t.Fatalf("tests[%d] - token start wrong. expected=%+v, got=%+v", i, tt.expectedStart, tok.Span.Start)
+		}
+
+		if tok.Span.End != tt.expectedEnd {
+			t.Fatalf("tests[%d] - token end wrong. expected=%+v, got=%+v", i, tt.expectedEnd, tok.Span.End)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	input := `"a\\b\r\x41\u{e9}\u{1F600}" ` + "`raw \\n\nline`" + ` """
+first
+\tsecond""" "" x "bad \q" y "\x4" "\u{110000}" "\u41" "open
+ z "open`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, "a\\b\rA\u00e9\U0001F600"},
+		{token.STRING, "raw \\n\nline"},
+		{token.STRING, "first\n\tsecond"},
+		{token.STRING, ""},
+		{token.IDENT, "x"},
+		{token.ERROR, "unknown escape sequence \\q"},
+		{token.IDENT, "y"},
+		{token.ERROR, "invalid escape sequence \\x4, want 2 hex digits"},
+		{token.ERROR, "invalid escape sequence \\u{110000}, not a unicode character"},
+		{token.ERROR, "invalid escape sequence \\u, want \\u{hex digits}"},
+		{token.ERROR, "unterminated string"},
+		{token.IDENT, "z"},
+		{token.ERROR, "unterminated string"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - token type wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := `"a ${x} b ${ {"k": "${y}"}["k"] } c" "${z}" "\${no}" """
+${w}
+"""`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING_START, "a "},
+		{token.IDENT, "x"},
+		{token.STRING_MIDDLE, " b "},
+		{token.LBRACE, "{"},
+		{token.STRING, "k"},
+		{token.COLON, ":"},
+		{token.STRING_START, ""},
+		{token.IDENT, "y"},
+		{token.STRING_END, ""},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "k"},
+		{token.RBRACKET, "]"},
+		{token.STRING_END, " c"},
+		{token.STRING_START, ""},
+		{token.IDENT, "z"},
+		{token.STRING_END, ""},
+		{token.STRING, "${no}"},
+		{token.STRING_START, ""},
+		{token.IDENT, "w"},
+		{token.STRING_END, "\n"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - token type wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := "5 3.14 1e-9 2.5E+3 10e2 7.a 1e 1.e"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "1e-9"},
+		{token.FLOAT, "2.5E+3"},
+		{token.FLOAT, "10e2"},
+		{token.INT, "7"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "a"},
+		{token.INT, "1"},
+		{token.IDENT, "e"},
+		{token.INT, "1"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "e"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - token type wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	input := "a && b || !c & d | e ...f"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.BANG, "!"},
+		{token.IDENT, "c"},
+		{token.ILLEGAL, "&"},
+		{token.IDENT, "d"},
+		{token.ILLEGAL, "|"},
+		{token.IDENT, "e"},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "f"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - token type wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - token literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
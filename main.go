@@ -1,20 +1,22 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/labasubagia/interpreter/compiler"
 	"github.com/labasubagia/interpreter/evaluator"
 	"github.com/labasubagia/interpreter/lexer"
 	"github.com/labasubagia/interpreter/object"
 	"github.com/labasubagia/interpreter/parser"
 	"github.com/labasubagia/interpreter/repl"
+	"github.com/labasubagia/interpreter/vm"
 )
 
-func eval(input string) {
-	env := object.NewEnvironment()
-
+func eval(input, file string) {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
@@ -22,22 +24,73 @@ func eval(input string) {
 		for i, e := range p.Errors() {
 			fmt.Printf("error parse-%d: %s\n", i, e)
 		}
+		return
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	limits, modules := limitsFromFlags(), modulesFromFlags()
+
+	var obj object.Object
+	switch *engine {
+	case "vm":
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			fmt.Printf("error compile: %s\n", err)
+			return
+		}
+		machine := vm.New(comp.Bytecode())
+		machine.SetLimits(limits)
+		machine.SetModules(modules)
+		machine.SetFile(file)
+		obj = machine.RunContext(ctx)
+	default:
+		env := object.NewEnvironment()
+		e := evaluator.New()
+		e.SetLimits(limits)
+		e.SetModules(modules)
+		e.SetFile(file)
+		obj = e.EvalContext(ctx, program, env, evaluator.ScopeNone)
 	}
-	obj := evaluator.Eval(program, env, evaluator.ScopeNone)
-	if obj != nil && obj.Type() == object.ERROR_OBJ {
-		fmt.Println(obj.Inspect())
+	if err, ok := obj.(*object.Error); ok {
+		fmt.Println(err.Traceback())
 	}
 }
 
+func limitsFromFlags() evaluator.Limits {
+	return evaluator.Limits{MaxSteps: *maxSteps, MaxDepth: *maxDepth, MaxAllocations: *maxAllocations}
+}
+
+func modulesFromFlags() *evaluator.Modules {
+	return evaluator.NewModules(filepath.SplitList(*searchPath)...)
+}
+
+var (
+	engine   = flag.String("engine", "eval", "engine to run programs with: eval or vm")
+	timeout  = flag.Duration("timeout", 0, "stop programs running longer than this, e.g. 5s")
+	maxSteps = flag.Int("max-steps", 0, "stop programs after this many steps")
+	maxDepth = flag.Int("max-depth", 0, "maximum depth of nested function calls")
+
+	maxAllocations = flag.Int("max-allocations", 0, "stop programs after allocating about this many objects")
+
+	searchPath = flag.String("path", "", "directories searched by imports, separated like PATH")
+)
+
 func main() {
+	flag.Parse()
+	args := flag.Args()
 
 	switch {
-	case len(os.Args) >= 3:
-		switch os.Args[1] {
+	case len(args) >= 2:
+		switch args[0] {
 		case "string":
-			eval(os.Args[2])
+			eval(args[1], "")
 		case "file":
-			file := os.Args[2]
+			file := args[1]
 
 			// using file
 			if ext := filepath.Ext(file); ext != ".newpl" {
@@ -47,10 +100,15 @@ func main() {
 			if err != nil {
 				panic(err)
 			}
-			eval(string(b))
+			eval(string(b), file)
 		}
 	default:
-		repl.Start(os.Stdin, os.Stdout)
+		repl.Start(os.Stdin, os.Stdout, repl.Options{
+			Engine:  *engine,
+			Limits:  limitsFromFlags(),
+			Timeout: *timeout,
+			Modules: modulesFromFlags(),
+		})
 	}
 
 }
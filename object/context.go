@@ -0,0 +1,47 @@
+package object
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// ExecContext is passed to builtins, it holds where a run reads and writes
+type ExecContext struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+
+	// Apply calls a function back from a builtin, e.g. the function given to map.
+	// The engine running the builtin sets it
+	Apply func(fn Object, args []Object) Object
+
+	stdin *bufio.Reader
+}
+
+// NewExecContext uses the standard streams of the process
+func NewExecContext() *ExecContext {
+	return &ExecContext{Stdout: os.Stdout, Stderr: os.Stderr, Stdin: os.Stdin}
+}
+
+// ReadLine reads a line from Stdin without the line ending, io.EOF when there is nothing left
+func (c *ExecContext) ReadLine() (string, error) {
+	if c.stdin == nil {
+		c.stdin = bufio.NewReader(c.Stdin)
+	}
+
+	line, err := c.stdin.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// Call calls fn with args from a builtin, errors raised by fn are returned
+func (c *ExecContext) Call(fn Object, args ...Object) Object {
+	if c.Apply == nil {
+		return NewError(GENERIC_ERROR, "cannot call %s outside of a run", fn.Inspect())
+	}
+	return c.Apply(fn, args)
+}
@@ -2,17 +2,25 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/labasubagia/interpreter/ast"
+	"github.com/labasubagia/interpreter/code"
+	"github.com/labasubagia/interpreter/token"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
@@ -21,9 +29,17 @@ const (
 	STRING_OBJ       = "STRING"
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
+	TUPLE_OBJ        = "TUPLE"
 	HASH_OBJ         = "HASH"
 	BREAK_OBJ        = "BREAK"
 	CONTINUE_OBJ     = "CONTINUE"
+	ITERATOR_OBJ     = "ITERATOR"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+
+	ANY_OBJ = "ANY" // not a type of any object, accepts every type in Builtin.Params
+
+	BIG_INTEGER_OBJ = "BIG_INTEGER" // only tells hash keys of big integers apart, they are INTEGER_OBJ
 )
 
 type Object interface {
@@ -47,6 +63,54 @@ func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+// BigInteger is an integer outside of the int64 range, scripts see it as an INTEGER.
+// Results that fit int64 again are Integers, see NewInteger. An object of type
+// INTEGER_OBJ may be either, read its value with Int64 rather than asserting *Integer
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (b *BigInteger) Type() ObjectType {
+	return INTEGER_OBJ
+}
+
+func (b *BigInteger) Inspect() string {
+	return b.Value.String()
+}
+
+// Int64 returns the value of an Integer, ok is false for a BigInteger or any other object
+func Int64(obj Object) (value int64, ok bool) {
+	if i, ok := obj.(*Integer); ok {
+		return i.Value, true
+	}
+	return 0, false
+}
+
+// NewInteger returns an Integer when value fits int64, otherwise a BigInteger
+func NewInteger(value *big.Int) Object {
+	if value.IsInt64() {
+		return &Integer{Value: value.Int64()}
+	}
+	return &BigInteger{Value: value}
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// Inspect keeps a decimal point on whole numbers, so 3.0 is not shown as the integer 3
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -81,8 +145,25 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// ErrorKind is the type of an error as seen by a catch block
+type ErrorKind string
+
+const (
+	GENERIC_ERROR = "Error"
+	TYPE_ERROR    = "TypeError"
+	NAME_ERROR    = "NameError"
+	VALUE_ERROR   = "ValueError"
+	INDEX_ERROR   = "IndexError"
+	IMPORT_ERROR  = "ImportError"
+	LIMIT_ERROR   = "LimitError" // execution limits, a catch block never sees it
+)
+
 type Error struct {
-	Message string
+	Kind     ErrorKind // empty is GENERIC_ERROR
+	Message  string
+	File     string         // file of Position, empty when the program is not a file, e.g. in the REPL
+	Position token.Position // where the error occurred
+	Stack    []StackFrame   // function calls active when the error occurred, outermost first
 }
 
 func (e *Error) Type() ObjectType {
@@ -93,10 +174,111 @@ func (e *Error) Inspect() string {
 	return "ERROR: " + e.Message
 }
 
+// NewError creates an error of the kind, builtins use it to raise typed errors
+func NewError(kind ErrorKind, format string, a ...any) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
+
+func (e *Error) KindName() string {
+	if e.Kind == "" {
+		return GENERIC_ERROR
+	}
+	return string(e.Kind)
+}
+
+// Catchable tells whether a catch block may handle the error
+func (e *Error) Catchable() bool {
+	return e.Kind != LIMIT_ERROR
+}
+
+// Error makes the error usable as a Go error, e.g. by host programs
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Traceback formats the error with its stack, most recent call last
+func (e *Error) Traceback() string {
+	var out bytes.Buffer
+
+	out.WriteString("Traceback (most recent call last):\n")
+	for _, line := range e.StackTrace() {
+		out.WriteString("  " + line + "\n")
+	}
+	out.WriteString(e.Inspect())
+
+	return out.String()
+}
+
+// maxRepeatedLines is the number of times the same line is shown in a row in a stack trace
+const maxRepeatedLines = 3
+
+// StackTrace is a line for every active function, most recent call last.
+// Like in Python, a line repeated more than maxRepeatedLines times in a row,
+// e.g. by a runaway recursion, is shown once with the number of repetitions
+func (e *Error) StackTrace() []string {
+	lines := []string{}
+	last, repeats := "", 0
+	collapse := func() {
+		if repeats > maxRepeatedLines {
+			lines = append(lines, fmt.Sprintf("[Previous line repeated %d more times]", repeats-maxRepeatedLines))
+		}
+	}
+	add := func(line string) {
+		if line != last {
+			collapse()
+			last, repeats = line, 0
+		}
+		repeats++
+		if repeats <= maxRepeatedLines {
+			lines = append(lines, line)
+		}
+	}
+
+	caller := "<main>"
+	for _, frame := range e.Stack {
+		add(traceLine(frame.File, frame.Position, caller))
+		caller = frame.FunctionName()
+	}
+	add(traceLine(e.File, e.Position, caller))
+	collapse()
+	return lines
+}
+
+func traceLine(file string, pos token.Position, function string) string {
+	line := "in " + function
+	if pos.IsValid() {
+		line = fmt.Sprintf("line %d, column %d, in %s", pos.Line, pos.Column, function)
+	}
+	if file != "" {
+		line = fmt.Sprintf("File \"%s\", %s", file, line)
+	}
+	return line
+}
+
+// ModuleFunction is the Function of the frame of an import, the module runs in it
+const ModuleFunction = "<module>"
+
+type StackFrame struct {
+	Function string         // empty when the function is anonymous, ModuleFunction for an import
+	File     string         // file of Position, empty when the program is not a file
+	Position token.Position // where the function is called
+}
+
+func (f StackFrame) FunctionName() string {
+	if f.Function == "" {
+		return "<anonymous>"
+	}
+	return f.Function
+}
+
 type Function struct {
+	Name       string // name of the first let binding, empty when anonymous
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // default value by parameter, nil when the parameter has none
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	File       string // file defining the function, empty when the program is not a file
 }
 
 func (f *Function) Type() ObjectType {
@@ -104,18 +286,17 @@ func (f *Function) Type() ObjectType {
 }
 
 func (f *Function) Inspect() string {
-	var out bytes.Buffer
+	return inspectFunction(f.Parameters, f.Defaults, f.Rest, f.Body)
+}
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
+func inspectFunction(parameters []*ast.Identifier, defaults []ast.Expression, rest *ast.Identifier, body *ast.BlockStatement) string {
+	var out bytes.Buffer
 
 	out.WriteString("fn")
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ast.ParametersString(parameters, defaults, rest))
 	out.WriteString(") {\n")
-	out.WriteString(f.Body.String())
+	out.WriteString(body.String())
 	out.WriteString("\n}")
 
 	return out.String()
@@ -133,10 +314,43 @@ func (s *String) Inspect() string {
 	return s.Value
 }
 
-type BuiltinFunction func(args ...Object) Object
+type BuiltinFunction func(ctx *ExecContext, args ...Object) Object
 
+// Builtin is a native function, its arguments are checked against Params before Fn is called
 type Builtin struct {
-	Fn BuiltinFunction
+	Name     string
+	Doc      string
+	Params   []ObjectType // expected type of each argument, ANY_OBJ accepts every type and INTEGER_OBJ big integers too
+	Variadic bool         // the last parameter accepts any number of arguments
+	Fn       BuiltinFunction
+}
+
+func (b *Builtin) Call(ctx *ExecContext, args ...Object) Object {
+	if err := b.checkArgs(args); err != nil {
+		return err
+	}
+	return b.Fn(ctx, args...)
+}
+
+func (b *Builtin) checkArgs(args []Object) *Error {
+	if b.Variadic {
+		if want := len(b.Params) - 1; len(args) < want {
+			return NewError(TYPE_ERROR, "wrong number of arguments. got=%d, want at least %d", len(args), want)
+		}
+	} else if len(args) != len(b.Params) {
+		return NewError(TYPE_ERROR, "wrong number of arguments. got=%d, want=%d", len(args), len(b.Params))
+	}
+	if len(b.Params) == 0 {
+		return nil
+	}
+
+	for i, arg := range args {
+		expected := b.Params[min(i, len(b.Params)-1)]
+		if expected != ANY_OBJ && arg.Type() != expected {
+			return NewError(TYPE_ERROR, "argument to `%s` must be %s, got %s", b.Name, expected, arg.Type())
+		}
+	}
+	return nil
 }
 
 func (b *Builtin) Type() ObjectType {
@@ -144,7 +358,10 @@ func (b *Builtin) Type() ObjectType {
 }
 
 func (b *Builtin) Inspect() string {
-	return "builtin function"
+	if b.Name == "" {
+		return "builtin function"
+	}
+	return fmt.Sprintf("builtin function %s", b.Name)
 }
 
 type Array struct {
@@ -170,6 +387,36 @@ func (a *Array) Inspect() string {
 	return out.String()
 }
 
+// Tuple is an immutable array of hashable elements, arrays used as hash keys become tuples
+type Tuple struct {
+	Elements []Object
+}
+
+// NewTuple freezes elements, it fails when one of them can not be a hash key
+func NewTuple(elements []Object) (*Tuple, bool) {
+	frozen := make([]Object, len(elements))
+	for i, el := range elements {
+		key, _, ok := HashKeyOf(el)
+		if !ok {
+			return nil, false
+		}
+		frozen[i] = key
+	}
+	return &Tuple{Elements: frozen}, true
+}
+
+func (t *Tuple) Type() ObjectType {
+	return TUPLE_OBJ
+}
+
+func (t *Tuple) Inspect() string {
+	elements := []string{}
+	for _, e := range t.Elements {
+		elements = append(elements, e.Inspect())
+	}
+	return "tuple(" + strings.Join(elements, ", ") + ")"
+}
+
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
@@ -189,19 +436,101 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+func (b *BigInteger) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(b.Value.String()))
+	return HashKey{Type: BIG_INTEGER_OBJ, Value: h.Sum64()}
+}
+
+// HashKey of a whole float is the one of the equal integer, as 1 == 1.0
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && !math.IsInf(f.Value, 0) {
+		value, _ := big.NewFloat(f.Value).Int(nil)
+		return NewInteger(value).(Hashable).HashKey()
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
+func (t *Tuple) HashKey() HashKey {
+	h := fnv.New64a()
+	for _, el := range t.Elements {
+		key := el.(Hashable).HashKey()
+		h.Write([]byte(key.Type))
+		h.Write(binary.LittleEndian.AppendUint64(nil, key.Value))
+	}
+	return HashKey{Type: t.Type(), Value: h.Sum64()}
+}
+
+// HashKeyOf returns obj as it is kept as a hash key and its HashKey, arrays become tuples
+// so changing them later does not change the key. ok is false for unhashable objects
+func HashKeyOf(obj Object) (key Object, hashKey HashKey, ok bool) {
+	if arr, isArray := obj.(*Array); isArray {
+		tuple, ok := NewTuple(arr.Elements)
+		if !ok {
+			return nil, HashKey{}, false
+		}
+		return tuple, tuple.HashKey(), true
+	}
+	hashable, ok := obj.(Hashable)
+	if !ok {
+		return nil, HashKey{}, false
+	}
+	return obj, hashable.HashKey(), true
+}
+
 type HashPair struct {
 	Key   Object
 	Value Object
 }
 
+// Hash keeps its pairs in insertion order, pairs are added with Set so the order is kept
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	Pairs  map[HashKey]HashPair
+	order  []HashKey
+	Caught *Error // set on the hash a catch block sees, throwing it again keeps the stack of the error
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// Set adds or replaces the pair of key, a new key goes after the others
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if _, ok := h.Pairs[key]; !ok {
+		h.order = append(h.order, key)
+	}
+	h.Pairs[key] = pair
+}
+
+// Delete removes the pair of key, the others keep their order
+func (h *Hash) Delete(key HashKey) (HashPair, bool) {
+	pair, ok := h.Pairs[key]
+	if !ok {
+		return pair, false
+	}
+	delete(h.Pairs, key)
+	for i, k := range h.order {
+		if k == key {
+			h.order = append(h.order[:i], h.order[i+1:]...)
+			break
+		}
+	}
+	return pair, true
+}
+
+// Ordered returns the pairs in insertion order
+func (h *Hash) Ordered() []HashPair {
+	pairs := make([]HashPair, len(h.order))
+	for i, key := range h.order {
+		pairs[i] = h.Pairs[key]
+	}
+	return pairs
 }
 
 func (h *Hash) Type() ObjectType {
@@ -212,7 +541,7 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	elements := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.Ordered() {
 		elements = append(elements, pair.Key.Inspect()+":"+pair.Value.Inspect())
 	}
 
@@ -244,3 +573,93 @@ func (c *Continue) Type() ObjectType {
 func (c *Continue) Inspect() string {
 	return "continue"
 }
+
+// Iterator walks a collection for a for-in loop, Next returns false when it is done
+type Iterator struct {
+	Next func() (key, value Object, ok bool)
+	Keys bool // a single loop variable gets the key instead of the value, e.g. for hashes
+}
+
+func (it *Iterator) Type() ObjectType {
+	return ITERATOR_OBJ
+}
+
+func (it *Iterator) Inspect() string {
+	return "iterator"
+}
+
+// SourcePosition maps the instruction at Offset onward to a source position
+type SourcePosition struct {
+	Offset   int
+	Position token.Position
+}
+
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	NumDefaults   int  // the last parameters have a default value
+	Rest          bool // extra arguments are collected in the local after the parameters
+	Name          string
+	Literal       *ast.FunctionLiteral // nil for the main program
+	Positions     []SourcePosition     // sorted by Offset
+	LocalNames    []string             // by local index, to report undefined locals
+	FreeNames     []string
+	Module        *Module // set when the vm loads the program of the function
+}
+
+// Module holds the constants and globals of a compiled program, shared by its functions
+type Module struct {
+	File        string // file of the program, empty when it is not a file
+	Constants   []Object
+	Globals     []Object
+	GlobalNames []string // by index, to report undefined globals
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FUNCTION_OBJ
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	if cf.Literal == nil {
+		return fmt.Sprintf("CompiledFunction[%p]", cf)
+	}
+	return inspectFunction(cf.Literal.Parameters, cf.Literal.Defaults, cf.Literal.Rest, cf.Literal.Body)
+}
+
+// PositionAt returns the source position of the instruction at offset
+func (cf *CompiledFunction) PositionAt(offset int) token.Position {
+	i := sort.Search(len(cf.Positions), func(i int) bool {
+		return cf.Positions[i].Offset > offset
+	})
+	if i == 0 {
+		return token.Position{}
+	}
+	return cf.Positions[i-1].Position
+}
+
+// Upvalue is a variable captured by a closure, Location points into
+// the vm stack while the variable is alive and to Closed afterwards
+type Upvalue struct {
+	Location *Object
+	Closed   Object
+}
+
+func (u *Upvalue) Close() {
+	u.Closed = *u.Location
+	u.Location = &u.Closed
+}
+
+// Closure is a function of the vm, to scripts it is the same as Function
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Upvalue
+}
+
+func (c *Closure) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+
+func (c *Closure) Inspect() string {
+	return c.Fn.Inspect()
+}
@@ -1,6 +1,12 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/labasubagia/interpreter/token"
+)
 
 func TestHash(t *testing.T) {
 	key1 := &String{Value: "key"}
@@ -15,6 +21,32 @@ func TestHash(t *testing.T) {
 	}
 }
 
+func TestHashOrder(t *testing.T) {
+	hash := NewHash()
+	for _, key := range []string{"b", "a", "c", "a"} {
+		k := &String{Value: key}
+		hash.Set(k.HashKey(), HashPair{Key: k, Value: &String{Value: "v" + key}})
+	}
+
+	if hash.Inspect() != "{b:vb, a:va, c:vc}" {
+		t.Errorf("wrong order. got=%s", hash.Inspect())
+	}
+	if len(hash.Ordered()) != len(hash.Pairs) {
+		t.Errorf("wrong number of pairs. want=%d, got=%d", len(hash.Pairs), len(hash.Ordered()))
+	}
+
+	a := &String{Value: "a"}
+	if pair, ok := hash.Delete(a.HashKey()); !ok || pair.Value.Inspect() != "va" {
+		t.Errorf("wrong deleted pair. got=%+v, %t", pair, ok)
+	}
+	if _, ok := hash.Delete(a.HashKey()); ok {
+		t.Errorf("deleted a missing key")
+	}
+	if hash.Inspect() != "{b:vb, c:vc}" {
+		t.Errorf("wrong order after delete. got=%s", hash.Inspect())
+	}
+}
+
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
 	hello2 := &String{Value: "Hello World"}
@@ -33,3 +65,180 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestBuiltinCall(t *testing.T) {
+	identity := func(ctx *ExecContext, args ...Object) Object { return args[0] }
+
+	tests := []struct {
+		builtin  *Builtin
+		args     []Object
+		expected string
+	}{
+		{
+			&Builtin{Name: "f", Params: []ObjectType{INTEGER_OBJ}, Fn: identity},
+			[]Object{&Integer{Value: 1}},
+			"1",
+		},
+		{
+			&Builtin{Name: "f", Params: []ObjectType{INTEGER_OBJ}, Fn: identity},
+			[]Object{},
+			"ERROR: wrong number of arguments. got=0, want=1",
+		},
+		{
+			&Builtin{Name: "f", Params: []ObjectType{INTEGER_OBJ}, Fn: identity},
+			[]Object{&String{Value: "a"}},
+			"ERROR: argument to `f` must be INTEGER, got STRING",
+		},
+		{
+			&Builtin{Name: "f", Params: []ObjectType{ANY_OBJ}, Fn: identity},
+			[]Object{&String{Value: "a"}},
+			"a",
+		},
+		{
+			&Builtin{Name: "f", Params: []ObjectType{STRING_OBJ, INTEGER_OBJ}, Variadic: true, Fn: identity},
+			[]Object{&String{Value: "a"}, &Integer{Value: 1}, &Integer{Value: 2}},
+			"a",
+		},
+		{
+			&Builtin{Name: "f", Params: []ObjectType{STRING_OBJ, INTEGER_OBJ}, Variadic: true, Fn: identity},
+			[]Object{&String{Value: "a"}, &Integer{Value: 1}, &String{Value: "b"}},
+			"ERROR: argument to `f` must be INTEGER, got STRING",
+		},
+		{
+			&Builtin{Name: "f", Params: []ObjectType{STRING_OBJ, INTEGER_OBJ}, Variadic: true, Fn: identity},
+			[]Object{},
+			"ERROR: wrong number of arguments. got=0, want at least 1",
+		},
+	}
+
+	for _, tt := range tests {
+		result := tt.builtin.Call(NewExecContext(), tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result. want=%q, got=%q", tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{3.14, "3.14"},
+		{3, "3.0"},
+		{-2, "-2.0"},
+		{1e-9, "1e-09"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+	}
+
+	for _, tt := range tests {
+		f := &Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("wrong inspect. want=%q, got=%q", tt.expected, f.Inspect())
+		}
+	}
+}
+
+func TestBigIntegerHashKey(t *testing.T) {
+	value, _ := new(big.Int).SetString("100000000000000000000", 10)
+	same, _ := new(big.Int).SetString("100000000000000000000", 10)
+	big1 := &BigInteger{Value: value}
+	big2 := &BigInteger{Value: same}
+	diff := &BigInteger{Value: new(big.Int).Add(value, big.NewInt(1))}
+
+	if big1.HashKey() != big2.HashKey() {
+		t.Errorf("big integers with same value have different hash keys")
+	}
+
+	if big1.HashKey() == diff.HashKey() {
+		t.Errorf("big integers with different value have same hash keys")
+	}
+}
+
+func TestFloatHashKey(t *testing.T) {
+	huge, _ := new(big.Int).SetString("100000000000000000000", 10)
+	tests := []struct {
+		float   float64
+		integer Hashable
+	}{
+		{1, &Integer{Value: 1}},
+		{-3, &Integer{Value: -3}},
+		{math.Copysign(0, -1), &Integer{Value: 0}},
+		{1e20, &BigInteger{Value: huge}},
+	}
+
+	for _, tt := range tests {
+		if (&Float{Value: tt.float}).HashKey() != tt.integer.HashKey() {
+			t.Errorf("float %v has a different hash key than the equal integer", tt.float)
+		}
+	}
+
+	if (&Float{Value: 1.5}).HashKey() == (&Integer{Value: 1}).HashKey() {
+		t.Errorf("float 1.5 has the hash key of integer 1")
+	}
+}
+
+func TestTupleHashKey(t *testing.T) {
+	one, two := &Integer{Value: 1}, &Integer{Value: 2}
+	tuple1, _ := NewTuple([]Object{one, &Array{Elements: []Object{two}}})
+	tuple2, _ := NewTuple([]Object{one, &Array{Elements: []Object{two}}})
+	diff, _ := NewTuple([]Object{&Array{Elements: []Object{two}}, one})
+
+	if tuple1.HashKey() != tuple2.HashKey() {
+		t.Errorf("tuples with same elements have different hash keys")
+	}
+	if tuple1.HashKey() == diff.HashKey() {
+		t.Errorf("tuples with different elements have same hash keys")
+	}
+	if _, ok := tuple1.Elements[1].(*Tuple); !ok {
+		t.Errorf("array element is not frozen. got=%T", tuple1.Elements[1])
+	}
+
+	_, key, ok := HashKeyOf(&Array{Elements: []Object{one, &Array{Elements: []Object{two}}}})
+	if !ok || key != tuple1.HashKey() {
+		t.Errorf("array has different hash key than its tuple")
+	}
+	if _, ok := NewTuple([]Object{NewHash()}); ok {
+		t.Errorf("tuple with a hash element should fail")
+	}
+}
+
+func TestNewInteger(t *testing.T) {
+	if _, ok := NewInteger(big.NewInt(42)).(*Integer); !ok {
+		t.Errorf("value in int64 range is not an Integer")
+	}
+
+	value := new(big.Int).Lsh(big.NewInt(1), 64)
+	if _, ok := NewInteger(value).(*BigInteger); !ok {
+		t.Errorf("value out of int64 range is not a BigInteger")
+	}
+}
+
+func TestStackTraceRepeats(t *testing.T) {
+	at := func(line int) token.Position { return token.Position{Line: line, Column: 1} }
+	err := &Error{Message: "boom", Position: at(9)}
+	err.Stack = append(err.Stack, StackFrame{Function: "f", Position: at(1)})
+	for i := 0; i < 5; i++ {
+		err.Stack = append(err.Stack, StackFrame{Function: "f", Position: at(2)})
+	}
+	err.Stack = append(err.Stack, StackFrame{Function: "g", Position: at(2)})
+
+	expected := []string{
+		"line 1, column 1, in <main>",
+		"line 2, column 1, in f",
+		"line 2, column 1, in f",
+		"line 2, column 1, in f",
+		"[Previous line repeated 3 more times]",
+		"line 9, column 1, in g",
+	}
+	lines := err.StackTrace()
+	if len(lines) != len(expected) {
+		t.Fatalf("wrong number of lines. want=%d, got=%d (%q)", len(expected), len(lines), lines)
+	}
+	for i, line := range expected {
+		if lines[i] != line {
+			t.Errorf("wrong line %d. want=%q, got=%q", i, line, lines[i])
+		}
+	}
+}
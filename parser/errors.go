@@ -0,0 +1,18 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/labasubagia/interpreter/token"
+)
+
+type ParseError struct {
+	Message  string
+	Expected token.TokenType // empty when no particular token was expected
+	Actual   token.Token
+	Span     token.Span
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Span.Start, e.Message)
+}
@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
 
 	"github.com/labasubagia/interpreter/ast"
@@ -13,6 +15,8 @@ const (
 	_ int = iota
 	LOWEST
 	ASSIGN       // =
+	OR           // ||
+	AND          // &&
 	EQUALS       // ==
 	LESS_GREATER // > or <
 	SUM          // +
@@ -28,6 +32,8 @@ type (
 )
 
 var precedences = map[token.TokenType]int{
+	token.OR:              OR,
+	token.AND:             AND,
 	token.EQ:              EQUALS,
 	token.NOT_EQ:          EQUALS,
 	token.LT:              LESS_GREATER,
@@ -53,7 +59,11 @@ type Parser struct {
 	l              *lexer.Lexer
 	curToken       token.Token
 	peekToken      token.Token
-	errors         []string
+	errors         []*ParseError
+	panicking      bool // an error was found, the statement is dropped and synchronized
+	braces         int  // number of unclosed '{' up to curToken
+	blockLevel     int  // value of braces inside the current block statement
+	loops          int  // number of loops around curToken in the current function
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
@@ -61,7 +71,7 @@ type Parser struct {
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []*ParseError{},
 	}
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
@@ -70,6 +80,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
@@ -78,9 +89,12 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.STRING_START, p.parseInterpolatedString)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 	p.registerPrefix(token.NULL, p.parseNull)
+	p.registerPrefix(token.IMPORT, p.parseImportExpression)
+	p.registerPrefix(token.ERROR, p.parseErrorToken)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -94,6 +108,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.LTE, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.GTE, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseLogicalExpression)
+	p.registerInfix(token.OR, p.parseLogicalExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
@@ -106,13 +122,73 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() []*ParseError {
 	return p.errors
 }
 
+// addError records only the first error of a statement,
+// the rest are usually caused by the first one
+func (p *Parser) addError(err *ParseError) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+	p.errors = append(p.errors, err)
+}
+
+// synchronize skips tokens until curToken is at the start of the next statement
+// or at the '}' closing the current block. A block of the statement, e.g. the body
+// of an if, ends it unless an else, catch or finally follows
+func (p *Parser) synchronize() {
+	p.panicking = false
+	for !p.curTokenIs(token.EOF) {
+		if p.curTokenIs(token.RBRACE) && p.braces < p.blockLevel {
+			return
+		}
+		if p.curTokenIs(token.RBRACE) && p.braces == p.blockLevel && !continuesStatement(p.peekToken.Type) {
+			p.nextToken()
+			if p.curTokenIs(token.SEMICOLON) {
+				p.nextToken()
+			}
+			return
+		}
+		if p.curTokenIs(token.SEMICOLON) && p.braces == p.blockLevel {
+			p.nextToken()
+			return
+		}
+		p.nextToken()
+		if isStatementKeyword(p.curToken.Type) && p.braces == p.blockLevel {
+			return
+		}
+	}
+}
+
+func isStatementKeyword(t token.TokenType) bool {
+	switch t {
+	case token.LET, token.RETURN, token.WHILE, token.BREAK, token.CONTINUE, token.TRY, token.THROW, token.EXPORT:
+		return true
+	}
+	return false
+}
+
+func continuesStatement(t token.TokenType) bool {
+	switch t {
+	case token.ELSE, token.CATCH, token.FINALLY:
+		return true
+	}
+	return false
+}
+
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	switch {
+	case p.curTokenIs(token.LBRACE):
+		p.braces += 1
+	case p.curTokenIs(token.RBRACE) && p.braces > 0:
+		p.braces -= 1
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -122,6 +198,10 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 	for !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
+		if p.panicking {
+			p.synchronize()
+			continue
+		}
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
@@ -130,10 +210,43 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+// parseErrorToken reports the malformed token found by the lexer
+func (p *Parser) parseErrorToken() ast.Expression {
+	p.addError(&ParseError{Message: p.curToken.Literal, Actual: p.curToken, Span: p.curToken.Span})
+	return nil
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+	str.Parts = append(str.Parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+
+	for !p.curTokenIs(token.STRING_END) {
+		if p.peekTokenIs(token.STRING_MIDDLE) || p.peekTokenIs(token.STRING_END) {
+			p.addError(&ParseError{Message: "empty expression in string interpolation", Actual: p.peekToken, Span: p.peekToken.Span})
+			return nil
+		}
+		p.nextToken()
+		exp := p.parseExpression(LOWEST)
+		if exp == nil {
+			return nil
+		}
+		str.Parts = append(str.Parts, exp)
+
+		if p.peekTokenIs(token.STRING_MIDDLE) {
+			p.nextToken()
+		} else if !p.expectPeek(token.STRING_END) {
+			return nil
+		}
+		str.Parts = append(str.Parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+	}
+
+	return str
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	// defer untrace(trace("parseStatement"))
 
@@ -144,10 +257,18 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseReturnStatement()
 	case token.WHILE:
 		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
 	case token.BREAK:
 		return p.parseBreakStatement()
 	case token.CONTINUE:
 		return p.parseContinueStatement()
+	case token.TRY:
+		return p.parseTryStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -155,26 +276,186 @@ func (p *Parser) parseStatement() ast.Statement {
 
 func (p *Parser) parseWhileStatement() *ast.WhileStatement {
 	stmt := &ast.WhileStatement{Token: p.curToken}
-	p.nextToken()
 
-	if !p.curTokenIs(token.LPAREN) {
+	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
 	p.nextToken()
 
 	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	return stmt
+}
+
+func (p *Parser) parseTryStatement() *ast.TryStatement {
+	stmt := &ast.TryStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			stmt.CatchParam = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		stmt.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		stmt.Finally = p.parseBlockStatement()
+	}
+
+	if stmt.Catch == nil && stmt.Finally == nil {
+		msg := fmt.Sprintf("try needs a catch or a finally block, got %s instead", p.peekToken.Type)
+		p.addError(&ParseError{Message: msg, Actual: p.peekToken, Span: p.peekToken.Span})
+		return nil
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseForStatement parses both the C-style for and the for-in statement
+func (p *Parser) parseForStatement() ast.Statement {
+	forToken := p.curToken
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+
+	if p.curTokenIs(token.IDENT) && (p.peekTokenIs(token.IN) || p.peekTokenIs(token.COMMA)) {
+		return p.parseForInStatement(forToken)
+	}
+
+	stmt := &ast.ForStatement{Token: forToken}
+
+	if !p.curTokenIs(token.SEMICOLON) {
+		stmt.Init = p.parseForInit()
+		if stmt.Init == nil || !p.expectPeek(token.SEMICOLON) {
+			return nil
+		}
+	}
+
+	if !p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		stmt.Condition = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.SEMICOLON) {
+		return nil
+	}
+
+	if !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		stmt.Update = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+
+	return stmt
+}
+
+// parseForInit parses a let statement or an expression, without the semicolon after it
+func (p *Parser) parseForInit() ast.Statement {
+	if !p.curTokenIs(token.LET) {
+		stmt := &ast.ExpressionStatement{Token: p.curToken}
+		stmt.Expression = p.parseExpression(LOWEST)
+		if stmt.Expression == nil {
+			return nil
+		}
+		return stmt
+	}
+
+	stmt := &ast.LetStatement{Token: p.curToken}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
 	p.nextToken()
 
-	if !p.curTokenIs(token.RPAREN) {
+	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
+	return stmt
+}
+
+func (p *Parser) parseForInStatement(forToken token.Token) ast.Statement {
+	stmt := &ast.ForInStatement{Token: forToken}
+	stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Key = stmt.Value
+		stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.IN) {
 		return nil
 	}
 	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
 
-	if !p.curTokenIs(token.LBRACE) {
+	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
 
-	stmt.Body = p.parseBlockStatement()
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
 
 	return stmt
 }
@@ -214,57 +495,149 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+func (p *Parser) parseExportStatement() *ast.ExportStatement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+
+	if p.blockLevel > 0 {
+		msg := "export is only allowed at the top level of a module"
+		p.addError(&ParseError{Message: msg, Actual: p.curToken, Span: p.curToken.Span})
+		return nil
+	}
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+
+	stmt.Statement = p.parseLetStatement()
+	if stmt.Statement == nil {
+		return nil
+	}
+	return stmt
+}
+
+func (p *Parser) parseImportExpression() ast.Expression {
+	expr := &ast.ImportExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	expr.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	return expr
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 
+	// a loop around the function does not contain its body
+	loops := p.loops
+	p.loops = 0
 	lit.Body = p.parseBlockStatement()
+	p.loops = loops
 
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseFunctionParameters parses the parameters and their default values,
+// parameters after one with a default value must have one too
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
 
 	// if next token is token.RPAREN, means no parameters
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
-	p.nextToken()
-
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	if !p.parseFunctionParameter(lit) {
+		return false
+	}
 
 	// if there is still a comma
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken() // point to token.COMMA
-		p.nextToken() // skip token.COMMA
-
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		if lit.Rest != nil {
+			msg := fmt.Sprintf("rest parameter ...%s must be the last parameter", lit.Rest.Value)
+			p.addError(&ParseError{Message: msg, Actual: p.curToken, Span: p.curToken.Span})
+			return false
+		}
+		if !p.parseFunctionParameter(lit) {
+			return false
+		}
 	}
 
 	// check end of functions params
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+	return p.expectPeek(token.RPAREN)
+}
+
+func (p *Parser) parseFunctionParameter(lit *ast.FunctionLiteral) bool {
+	if p.peekTokenIs(token.ELLIPSIS) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
+		lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		return p.checkNewParameter(lit, lit.Rest)
 	}
 
-	return identifiers
+	if !p.expectPeek(token.IDENT) {
+		return false
+	}
+
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.checkNewParameter(lit, ident) {
+		return false
+	}
+	lit.Parameters = append(lit.Parameters, ident)
+
+	if !p.peekTokenIs(token.ASSIGN) {
+		if len(lit.Defaults) > 0 {
+			msg := fmt.Sprintf("parameter %s needs a default value, it follows a parameter with one", ident.Value)
+			p.addError(&ParseError{Message: msg, Actual: p.curToken, Span: p.curToken.Span})
+			return false
+		}
+		return true
+	}
+	p.nextToken()
+	p.nextToken()
+
+	for len(lit.Defaults) < len(lit.Parameters)-1 {
+		lit.Defaults = append(lit.Defaults, nil)
+	}
+	value := p.parseExpression(LOWEST)
+	if value == nil {
+		return false
+	}
+	lit.Defaults = append(lit.Defaults, value)
+	return true
+}
+
+// checkNewParameter reports a parameter named like a previous one
+func (p *Parser) checkNewParameter(lit *ast.FunctionLiteral, ident *ast.Identifier) bool {
+	for _, param := range lit.Parameters {
+		if param.Value == ident.Value {
+			msg := fmt.Sprintf("duplicate parameter %s", ident.Value)
+			p.addError(&ParseError{Message: msg, Actual: p.curToken, Span: p.curToken.Span})
+			return false
+		}
+	}
+	return true
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Rparen = p.curToken
 	return exp
 }
 
@@ -286,6 +659,9 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 func (p *Parser) parseBreakStatement() *ast.BreakStatement {
 	stmt := &ast.BreakStatement{Token: p.curToken}
+	if !p.checkInsideLoop() {
+		return nil
+	}
 	for p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -294,12 +670,25 @@ func (p *Parser) parseBreakStatement() *ast.BreakStatement {
 
 func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
 	stmt := &ast.ContinueStatement{Token: p.curToken}
+	if !p.checkInsideLoop() {
+		return nil
+	}
 	for p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return stmt
 }
 
+// checkInsideLoop reports a break or continue outside of a loop of the current function
+func (p *Parser) checkInsideLoop() bool {
+	if p.loops > 0 {
+		return true
+	}
+	msg := fmt.Sprintf("%s is only allowed inside a loop", p.curToken.Literal)
+	p.addError(&ParseError{Message: msg, Actual: p.curToken, Span: p.curToken.Span})
+	return false
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	// defer untrace(trace("parseExpressionStatement"))
 
@@ -313,7 +702,7 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(&ParseError{Message: msg, Actual: p.curToken, Span: p.curToken.Span})
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
@@ -365,6 +754,19 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseLogicalExpression(left ast.Expression) ast.Expression {
+	expression := &ast.LogicalExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Left:     left,
+	}
+	precedence := p.curPrecedence()
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
+
+	return expression
+}
+
 func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
 
@@ -398,18 +800,38 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loops++
+	defer func() { p.loops-- }()
+	return p.parseBlockStatement()
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
 
+	outerLevel := p.blockLevel
+	p.blockLevel = p.braces
+	defer func() { p.blockLevel = outerLevel }()
+
 	p.nextToken()
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
+		if p.panicking {
+			p.synchronize()
+			continue
+		}
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	block.Rbrace = p.curToken
+
+	if p.curTokenIs(token.EOF) {
+		msg := fmt.Sprintf("expected %s to close block started at %s, got EOF instead", token.RBRACE, block.Token.Span.Start)
+		p.addError(&ParseError{Message: msg, Expected: token.RBRACE, Actual: p.curToken, Span: p.curToken.Span})
+	}
 
 	return block
 }
@@ -431,9 +853,29 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if n, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			lit.Big = n
+			return lit
+		}
+	}
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(&ParseError{Message: msg, Actual: p.curToken, Span: p.curToken.Span})
+		return nil
+	}
+
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
+		p.addError(&ParseError{Message: msg, Actual: p.curToken, Span: p.curToken.Span})
 		return nil
 	}
 
@@ -455,9 +897,26 @@ func (p *Parser) parseIdentifier() ast.Expression {
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken
 	return array
 }
 
+// parseListElement parses an argument of a call or an element of an array literal,
+// the only places where a spread is allowed
+func (p *Parser) parseListElement() ast.Expression {
+	if !p.curTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.curToken}
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+	if spread.Value == nil {
+		return nil
+	}
+	return spread
+}
+
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	list := []ast.Expression{}
 
@@ -469,13 +928,13 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 
 	// one item in list
 	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	list = append(list, p.parseListElement())
 
 	// more than one item in list
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken() // curToken pointing to comma
 		p.nextToken() // curToken pointing to value after comma
-		list = append(list, p.parseExpression(LOWEST))
+		list = append(list, p.parseListElement())
 	}
 
 	// check if list valid
@@ -489,19 +948,51 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
 
-	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		exp.Index = p.parseExpression(LOWEST)
+	}
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(exp.Token, left, exp.Index)
+	}
 
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	exp.Rbracket = p.curToken
 
 	return exp
 }
 
+// parseSliceExpression parses the rest of left[start:end:step] from the first ':'
+func (p *Parser) parseSliceExpression(tok token.Token, left, start ast.Expression) ast.Expression {
+	slice := &ast.SliceExpression{Token: tok, Left: left, Start: start}
+
+	p.nextToken()
+	if !p.peekTokenIs(token.COLON) && !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		slice.End = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if !p.peekTokenIs(token.RBRACKET) {
+			p.nextToken()
+			slice.Step = p.parseExpression(LOWEST)
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	slice.Rbracket = p.curToken
+
+	return slice
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
-	hash.Pairs = make(map[ast.Expression]ast.Expression)
+	hash.Pairs = []ast.HashPair{}
 
 	// loop until token.BRACE
 	for !p.peekTokenIs(token.RBRACE) {
@@ -520,7 +1011,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
 
 		// when next token not brace
 		// and comma not found in all token on the right
@@ -534,6 +1025,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.Rbrace = p.curToken
 
 	return hash
 }
@@ -569,7 +1061,10 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	if p.peekTokenIs(token.ERROR) {
+		msg = p.peekToken.Literal
+	}
+	p.addError(&ParseError{Message: msg, Expected: t, Actual: p.peekToken, Span: p.peekToken.Span})
 }
 
 func (p *Parser) peekPrecedence() int {
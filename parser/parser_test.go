@@ -2,10 +2,12 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/labasubagia/interpreter/ast"
 	"github.com/labasubagia/interpreter/lexer"
+	"github.com/labasubagia/interpreter/token"
 )
 
 func TestLetStatements(t *testing.T) {
@@ -114,6 +116,54 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "100000000000000000000;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Big == nil || literal.Big.String() != "100000000000000000000" {
+		t.Errorf("literal.Big wrong. got=%v", literal.Big)
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e-9", 1e-9},
+		{"2.5E+3", 2500},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %v. got=%v", tt.expected, literal.Value)
+		}
+		if literal.TokenLiteral() != strings.TrimSuffix(tt.input, ";") {
+			t.Errorf("literal.TokenLiteral not %s. got=%s", tt.input, literal.TokenLiteral())
+		}
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -316,6 +366,30 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"f(a, ...b, ...[c, d])",
+			"f(a, ...b, ...[c, d])",
+		},
+		{
+			"[...a, b + c]",
+			"[...a, (b + c)]",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a < b && !c == d",
+			"((a < b) && ((!c) == d))",
+		},
+		{
+			"x = a || b",
+			"x = (a || b);",
+		},
 	}
 
 	for _, tt := range tests {
@@ -505,12 +579,18 @@ func TestFunctionLiteralParsing(t *testing.T) {
 
 func TestFunctionParametersParsing(t *testing.T) {
 	tests := []struct {
-		input          string
-		expectedParams []string
+		input            string
+		expectedParams   []string
+		expectedDefaults []string // empty when the parameter has no default value
+		expectedRest     string
 	}{
 		{input: "fn() {};", expectedParams: []string{}},
 		{input: "fn(x) {};", expectedParams: []string{"x"}},
 		{input: "fn(x, y) {};", expectedParams: []string{"x", "y"}},
+		{input: "fn(x, y = 10) {};", expectedParams: []string{"x", "y"}, expectedDefaults: []string{"", "10"}},
+		{input: "fn(x = 1, y = x * 2) {};", expectedParams: []string{"x", "y"}, expectedDefaults: []string{"1", "(x * 2)"}},
+		{input: "fn(...args) {};", expectedParams: []string{}, expectedRest: "args"},
+		{input: "fn(x, y = 2, ...rest) {};", expectedParams: []string{"x", "y"}, expectedDefaults: []string{"", "2"}, expectedRest: "rest"},
 	}
 
 	for _, tt := range tests {
@@ -530,6 +610,24 @@ func TestFunctionParametersParsing(t *testing.T) {
 		for i, ident := range tt.expectedParams {
 			testLiteralExpression(t, function.Parameters[i], ident)
 		}
+
+		for i, expected := range tt.expectedDefaults {
+			var actual string
+			if d := function.Defaults[i]; d != nil {
+				actual = d.String()
+			}
+			if actual != expected {
+				t.Errorf("default of parameter %d wrong. want %q, got %q", i, expected, actual)
+			}
+		}
+
+		var rest string
+		if function.Rest != nil {
+			rest = function.Rest.Value
+		}
+		if rest != tt.expectedRest {
+			t.Errorf("rest parameter wrong. want %q, got %q", tt.expectedRest, rest)
+		}
 	}
 }
 
@@ -587,6 +685,37 @@ func TestStringExpression(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStringExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		parts    int
+	}{
+		{`"a ${x} b";`, `"a ${x} b"`, 3},
+		{`"${x + 1}${f(y)}";`, `"${(x + 1)}${f(y)}"`, 5},
+		{`"n: ${"${n}"}";`, `"n: ${"${n}"}"`, 3},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		str, ok := stmt.Expression.(*ast.InterpolatedString)
+		if !ok {
+			t.Fatalf("exp not ast.InterpolatedString. got=%T", stmt.Expression)
+		}
+		if len(str.Parts) != tt.parts {
+			t.Errorf("wrong number of parts. want=%d, got=%d", tt.parts, len(str.Parts))
+		}
+		if str.String() != tt.expected {
+			t.Errorf("wrong string. want=%q, got=%q", tt.expected, str.String())
+		}
+	}
+}
+
 func TestNullExpression(t *testing.T) {
 	input := `null`
 
@@ -647,6 +776,44 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:3]", "(a[1:3])"},
+		{"a[:n]", "(a[:n])"},
+		{"a[1:]", "(a[1:])"},
+		{"a[:]", "(a[:])"},
+		{"a[::2]", "(a[::2])"},
+		{"a[::]", "(a[:])"},
+		{"a[-1:0:-1]", "(a[(-1):0:(-1)])"},
+		{"a[i + 1:len(a) - 1][0]", "((a[(i + 1):(len(a) - 1)])[0])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		slice, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			slice, ok = stmt.Expression.(*ast.IndexExpression).Left.(*ast.SliceExpression)
+		}
+		if !ok {
+			t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+		}
+		if stmt.Expression.String() != tt.expected {
+			t.Errorf("wrong string. want=%q, got=%q", tt.expected, stmt.Expression.String())
+		}
+		if !testIdentifier(t, slice.Left, "a") {
+			return
+		}
+	}
+}
+
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one":  1, "two": 2, "three": 3}`
 
@@ -665,18 +832,24 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
-	expected := map[string]int64{
-		"one": 1, "two": 2, "three": 3,
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1}, {"two", 2}, {"three", 3},
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
 		}
 
-		expectedValue := expected[literal.String()]
-		testIntegerLiteral(t, value, expectedValue)
+		if literal.String() != expected[i].key {
+			t.Errorf("wrong key at %d. want=%q, got=%q", i, expected[i].key, literal.String())
+		}
+		testIntegerLiteral(t, pair.Value, expected[i].value)
 	}
 }
 
@@ -778,10 +951,10 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		},
 	}
 
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*ast.StringLiteral)
+	for _, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
 		}
 
 		testFunc, ok := tests[literal.String()]
@@ -789,7 +962,7 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 			t.Errorf("No test function for key %q found", literal.String())
 		}
 
-		testFunc(value)
+		testFunc(pair.Value)
 	}
 }
 
@@ -868,6 +1041,369 @@ func TestParsingWhileStatement(t *testing.T) {
 
 }
 
+func TestParsingForStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"for (let i = 0; i < 10; i += 1) { puts(i); }",
+			"for(let i = 0; (i < 10); i += 1){puts(i)}",
+		},
+		{
+			"for (i = 0; i < 10;) {}",
+			"for(i = 0; (i < 10); ){}",
+		},
+		{
+			"for (;;) { break; }",
+			"for(; ; ){break}",
+		},
+		{
+			"for (x in [1, 2]) { continue; }",
+			"for(x in [1, 2]){continue}",
+		},
+		{
+			"for (k, v in h) {}",
+			"for(k, v in h){}",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0]
+		switch stmt.(type) {
+		case *ast.ForStatement, *ast.ForInStatement:
+		default:
+			t.Fatalf("program.Statements[0] is not a for statement. got=%T", stmt)
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("stmt is not %q. got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestParsingTryStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"try { f(); } catch (e) { puts(e); }",
+			"try{f()}catch(e){puts(e)}",
+		},
+		{
+			"try { f(); } catch { }",
+			"try{f()}catch{}",
+		},
+		{
+			"try { f(); } finally { g(); }",
+			"try{f()}finally{g()}",
+		},
+		{
+			"try { f(); } catch (e) { } finally { g(); }",
+			"try{f()}catch(e){}finally{g()}",
+		},
+		{
+			"throw \"boom\";",
+			"throw boom;",
+		},
+		{
+			"throw {\"message\": \"boom\"}",
+			"throw {message:boom};",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0]
+		switch stmt.(type) {
+		case *ast.TryStatement, *ast.ThrowStatement:
+		default:
+			t.Fatalf("program.Statements[0] is not a try or throw statement. got=%T", stmt)
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("stmt is not %q. got=%q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestParsingImportExport(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let m = import "lib/math.newpl";`, "let m = import lib/math.newpl;"},
+		{`import "math.newpl"["square"](2)`, "(import math.newpl[square])(2)"},
+		{`export let x = 1;`, "export let x = 1;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		if program.Statements[0].String() != tt.expected {
+			t.Errorf("stmt is not %q. got=%q", tt.expected, program.Statements[0].String())
+		}
+	}
+}
+
+func TestNodeSpan(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x", "1:1-1:2"},
+		{"  foobar;", "1:3-1:9"},
+		{"let x = 5 * 10;", "1:1-1:15"},
+		{"return add(1, 2);", "1:1-1:17"},
+		{"-a + b", "1:1-1:7"},
+		{"arr[1 + 2]", "1:1-1:11"},
+		{"[1,\n 2]", "1:1-2:4"},
+		{`{"a": 1}`, "1:1-1:9"},
+		{"fn(x) {\n  x\n}", "1:1-3:2"},
+		{"if (x) { 1 } else { 2 }", "1:1-1:24"},
+		{"while (x) {\n  break;\n}", "1:1-3:2"},
+		{"a = 12", "1:1-1:7"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+
+		span := program.Statements[0].Span()
+		if span.String() != tt.expected {
+			t.Errorf("wrong span for %q. expected=%q, got=%q", tt.input, tt.expected, span.String())
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+	}{
+		{
+			"let = 5;",
+			[]string{"1:5: expected next token to be IDENT, got = instead"},
+		},
+		{
+			"let s = \"abc;\nlet t = \"a\\qb\";\nlet u = 1;",
+			[]string{
+				"1:9: unterminated string",
+				"2:9: unknown escape sequence \\q",
+			},
+		},
+		{
+			"let s \"abc\n",
+			[]string{"1:7: unterminated string"},
+		},
+		{
+			`let s = "a ${} b"; let t = "${1 2}"; let u = "${x`,
+			[]string{
+				"1:14: empty expression in string interpolation",
+				"1:33: expected next token to be STRING_END, got INT instead",
+				"1:50: expected next token to be STRING_END, got EOF instead",
+			},
+		},
+		{
+			"a[1:2:3:4]; a[1 2];",
+			[]string{
+				"1:8: expected next token to be ], got : instead",
+				"1:17: expected next token to be ], got INT instead",
+			},
+		},
+		{
+			`
+				let x 5;
+				let y = 10;
+				let = 2;
+				let z = 15;
+			`,
+			[]string{
+				"2:11: expected next token to be =, got INT instead",
+				"4:9: expected next token to be IDENT, got = instead",
+			},
+		},
+		{
+			`
+				while (x {
+					let y = 1;
+				}
+				while x) {}
+				let z = 2;
+			`,
+			[]string{
+				"2:14: expected next token to be ), got { instead",
+				"5:11: expected next token to be (, got IDENT instead",
+			},
+		},
+		{
+			`
+				let f = fn(x, 1) { x };
+				let g = fn(x) {
+					let = 1;
+					let y = * 2;
+					x
+				};
+				g(1;
+			`,
+			[]string{
+				"2:19: expected next token to be IDENT, got INT instead",
+				"4:10: expected next token to be IDENT, got = instead",
+				"5:14: no prefix parse function for * found",
+				"8:8: expected next token to be ), got ; instead",
+			},
+		},
+		{
+			"if (x > ) { puts(1) }\nputs(2 +)\n",
+			[]string{
+				"1:9: no prefix parse function for ) found",
+				"2:9: no prefix parse function for ) found",
+			},
+		},
+		{
+			"while (x { puts(1) }\nfn(a b) { a };\nlet y = 1;",
+			[]string{
+				"1:10: expected next token to be ), got { instead",
+				"2:6: expected next token to be ), got IDENT instead",
+			},
+		},
+		{
+			"if (x > ) { 1 } else { 2 }\nlet y = ;",
+			[]string{
+				"1:9: no prefix parse function for ) found",
+				"2:9: no prefix parse function for ; found",
+			},
+		},
+		{
+			"break;\nwhile (x) { fn() { continue; }; break; }\nlet f = fn() { if (x) { break; } };",
+			[]string{
+				"1:1: break is only allowed inside a loop",
+				"2:20: continue is only allowed inside a loop",
+				"3:25: break is only allowed inside a loop",
+			},
+		},
+		{
+			"fn(a, a) { a }; fn(a, b = 1, ...a) { a }",
+			[]string{
+				"1:7: duplicate parameter a",
+				"1:33: duplicate parameter a",
+			},
+		},
+		{
+			"if (x) { 1 + }",
+			[]string{"1:14: no prefix parse function for } found"},
+		},
+		{
+			"fn(x) { x",
+			[]string{"1:10: expected } to close block started at 1:7, got EOF instead"},
+		},
+		{
+			"fn(...xs, y) { y }",
+			[]string{"1:9: rest parameter ...xs must be the last parameter"},
+		},
+		{
+			"let x = ...y;",
+			[]string{"1:9: no prefix parse function for ... found"},
+		},
+		{
+			"fn(x = 1, y) { x }",
+			[]string{"1:11: parameter y needs a default value, it follows a parameter with one"},
+		},
+		{
+			"import x",
+			[]string{"1:8: expected next token to be STRING, got IDENT instead"},
+		},
+		{
+			"if (true) { export let x = 1; }",
+			[]string{"1:13: export is only allowed at the top level of a module"},
+		},
+		{
+			"export x = 1;",
+			[]string{"1:8: expected next token to be LET, got IDENT instead"},
+		},
+		{
+			"try { x } let y = 1;",
+			[]string{"1:11: try needs a catch or a finally block, got LET instead"},
+		},
+		{
+			"try { x } catch (1) { }",
+			[]string{"1:18: expected next token to be IDENT, got INT instead"},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			for _, err := range errors {
+				t.Errorf("parser error: %q", err.Error())
+			}
+			t.Fatalf("wrong number of errors for %q. expected=%d, got=%d", tt.input, len(tt.expectedErrors), len(errors))
+		}
+
+		for i, err := range errors {
+			if err.Error() != tt.expectedErrors[i] {
+				t.Errorf("wrong error. expected=%q, got=%q", tt.expectedErrors[i], err.Error())
+			}
+		}
+	}
+}
+
+func TestParseErrorDetail(t *testing.T) {
+	l := lexer.New("let x = [1, 2;")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("wrong number of errors. expected=1, got=%d", len(errors))
+	}
+
+	err := errors[0]
+	if err.Expected != token.RBRACKET {
+		t.Errorf("err.Expected is not %q. got=%q", token.RBRACKET, err.Expected)
+	}
+	if err.Actual.Type != token.SEMICOLON {
+		t.Errorf("err.Actual.Type is not %q. got=%q", token.SEMICOLON, err.Actual.Type)
+	}
+	if err.Span.Start.Offset != 13 || err.Span.End.Offset != 14 {
+		t.Errorf("err.Span is wrong. got=%+v", err.Span)
+	}
+}
+
 func testInfixExpression(t *testing.T, exp ast.Expression, left any, operator string, right any) bool {
 	opExp, ok := exp.(*ast.InfixExpression)
 	if !ok {
@@ -966,8 +1502,8 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	}
 
 	t.Errorf("parser has %d errors", len(errors))
-	for _, msg := range errors {
-		t.Errorf("parser error: %q", msg)
+	for _, err := range errors {
+		t.Errorf("parser error: %q", err.Error())
 	}
 	t.FailNow()
 }
@@ -1,34 +1,57 @@
 package repl
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"io"
+	"time"
 
+	"github.com/labasubagia/interpreter/ast"
+	"github.com/labasubagia/interpreter/compiler"
 	"github.com/labasubagia/interpreter/evaluator"
 	"github.com/labasubagia/interpreter/lexer"
 	"github.com/labasubagia/interpreter/object"
 	"github.com/labasubagia/interpreter/parser"
+	"github.com/labasubagia/interpreter/vm"
 )
 
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
+// Options configure how the lines are run, the zero value evaluates them without limits
+type Options struct {
+	Engine  string           // "eval" or "vm"
+	Limits  evaluator.Limits // bound every line
+	Timeout time.Duration    // stop a line running longer than this, zero means no timeout
+	Modules *evaluator.Modules
+}
 
-	fmt.Printf("This is the NEW Programming Language!\n")
-	fmt.Println("Feel free to type in commands")
+func Start(in io.Reader, out io.Writer, opts Options) {
 
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	fmt.Fprintf(out, "This is the NEW Programming Language!\n")
+	fmt.Fprintln(out, "Feel free to type in commands")
+
+	// builtins like puts write to out instead of the process stdout,
+	// lines are read through the context so input() shares the buffer
+	ctx := &object.ExecContext{Stdout: out, Stderr: out, Stdin: in}
+	if opts.Modules == nil {
+		opts.Modules = evaluator.NewModules()
+	}
+
+	var run func(ctx context.Context, program *ast.Program) object.Object
+	switch opts.Engine {
+	case "vm":
+		run = newMachine(ctx, opts)
+	default:
+		run = newEvaluator(ctx, opts)
+	}
 
 	for {
 		fmt.Fprint(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		line, err := ctx.ReadLine()
+		if err != nil {
 			return
 		}
 
-		line := scanner.Text()
 		l := lexer.New(line)
 		p := parser.New(l)
 		program := p.ParseProgram()
@@ -37,7 +60,12 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env, evaluator.ScopeNone)
+		evaluated := runLine(run, program, opts.Timeout)
+		if err, ok := evaluated.(*object.Error); ok {
+			io.WriteString(out, err.Traceback())
+			io.WriteString(out, "\n")
+			continue
+		}
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
@@ -45,8 +73,54 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParseErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+func runLine(run func(ctx context.Context, program *ast.Program) object.Object, program *ast.Program, timeout time.Duration) object.Object {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return run(ctx, program)
+}
+
+// newEvaluator runs lines with the evaluator, they share the environment
+func newEvaluator(execCtx *object.ExecContext, opts Options) func(ctx context.Context, program *ast.Program) object.Object {
+	env := object.NewEnvironment()
+	eval := evaluator.New()
+	eval.SetContext(execCtx)
+	eval.SetLimits(opts.Limits)
+	eval.SetModules(opts.Modules)
+
+	return func(ctx context.Context, program *ast.Program) object.Object {
+		return eval.EvalContext(ctx, program, env, evaluator.ScopeNone)
+	}
+}
+
+// newMachine runs lines with the vm, they share the globals and constants
+func newMachine(execCtx *object.ExecContext, opts Options) func(ctx context.Context, program *ast.Program) object.Object {
+	symbolTable := compiler.NewSymbolTable()
+	constants := []object.Object{}
+	globals := make([]object.Object, vm.GlobalsSize)
+
+	return func(ctx context.Context, program *ast.Program) object.Object {
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(program); err != nil {
+			compileErr := err.(*compiler.CompileError)
+			return &object.Error{Message: compileErr.Message, Position: compileErr.Position}
+		}
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		machine := vm.NewWithGlobalsStore(bytecode, globals)
+		machine.SetContext(execCtx)
+		machine.SetLimits(opts.Limits)
+		machine.SetModules(opts.Modules)
+		return machine.RunContext(ctx)
+	}
+}
+
+func printParseErrors(out io.Writer, errors []*parser.ParseError) {
+	for _, err := range errors {
+		io.WriteString(out, "\t"+err.Error()+"\n")
 	}
 }
@@ -0,0 +1,36 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/labasubagia/interpreter/evaluator"
+)
+
+func TestStart(t *testing.T) {
+	input := "let x = 2;\nlet double = fn(n) { n * x };\ndouble(21)\nwhile (true) {}\nx\n"
+	expected := []string{
+		">> >> >> 42",
+		">> Traceback (most recent call last):",
+		"ERROR: execution limit exceeded",
+		">> 2",
+		">> ",
+	}
+
+	for _, engine := range []string{"eval", "vm"} {
+		var out bytes.Buffer
+		Start(strings.NewReader(input), &out, Options{Engine: engine, Limits: evaluator.Limits{MaxSteps: 10000}})
+
+		// the banner and the positions of the traceback are skipped, the engines position errors differently
+		var lines []string
+		for _, line := range strings.Split(out.String(), "\n")[2:] {
+			if !strings.HasPrefix(line, "  line ") {
+				lines = append(lines, line)
+			}
+		}
+		if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("wrong output of %s. want=%q, got=%q", engine, expected, lines)
+		}
+	}
+}
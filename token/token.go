@@ -1,18 +1,53 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
+// Position is a location in the source, Line and Column start at 1
+type Position struct {
+	Offset int // byte offset, starting at 0
+	Line   int
+	Column int // byte column, starting at 1
+}
+
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Span is the half-open source range [Start, End)
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func (s Span) String() string {
+	return s.Start.String() + "-" + s.End.String()
+}
+
 type Token struct {
 	Type    TokenType
 	Literal string
+	Span    Span
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	ERROR   = "ERROR" // a malformed token, e.g. an unterminated string, Literal is the reason
 
 	IDENT = "IDENT"
 	INT   = "INT"
+	FLOAT = "FLOAT"
+
+	// an interpolated string "a ${x} b ${y} c" is STRING_START, x, STRING_MIDDLE, y, STRING_END
+	STRING_START  = "STRING_START"
+	STRING_MIDDLE = "STRING_MIDDLE"
+	STRING_END    = "STRING_END"
 
 	ASSIGN          = "="
 	PLUS            = "+"
@@ -34,6 +69,10 @@ const (
 	EQ     = "=="
 	NOT_EQ = "!="
 
+	AND = "&&"
+	OR  = "||"
+
+	ELLIPSIS  = "..."
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
@@ -59,6 +98,14 @@ const (
 	WHILE    = "WHILE"
 	BREAK    = "BREAK"
 	CONTINUE = "CONTINUE"
+	FOR      = "FOR"
+	IN       = "IN"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
 )
 
 var keywords = map[string]TokenType{
@@ -73,6 +120,14 @@ var keywords = map[string]TokenType{
 	"while":    WHILE,
 	"break":    BREAK,
 	"continue": CONTINUE,
+	"for":      FOR,
+	"in":       IN,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
+	"import":   IMPORT,
+	"export":   EXPORT,
 }
 
 func LookupIdent(ident string) TokenType {
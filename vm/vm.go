@@ -0,0 +1,996 @@
+package vm
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/labasubagia/interpreter/ast"
+	"github.com/labasubagia/interpreter/code"
+	"github.com/labasubagia/interpreter/compiler"
+	"github.com/labasubagia/interpreter/evaluator"
+	"github.com/labasubagia/interpreter/object"
+)
+
+const (
+	StackSize   = 2048 // initial size of the stack, it grows as needed
+	GlobalsSize = 65536
+	MaxFrames   = 1024 // initial number of frames, they grow up to the depth limit
+)
+
+var (
+	NULL  = evaluator.NULL
+	TRUE  = evaluator.TRUE
+	FALSE = evaluator.FALSE
+)
+
+type VM struct {
+	module   *object.Module // of the main program, every function points to the module of its program
+	builtins evaluator.Builtins
+	ctx      *object.ExecContext
+
+	limits      evaluator.Limits
+	done        <-chan struct{} // closed when the run must stop
+	steps       int
+	allocations int
+
+	stack []object.Object
+	sp    int // always points to the next free slot, top of stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	// upvalues still pointing into the stack, by stack index
+	openUpvalues map[int]*object.Upvalue
+
+	handlers []handler // active try blocks, innermost last
+
+	base  int                                                        // frames below the function run by the current run loop
+	apply func(fn object.Object, args []object.Object) object.Object // calls back from builtins
+
+	modules *evaluator.Modules
+}
+
+// handler is where an error raised inside a try block continues
+type handler struct {
+	catch       int // instruction of the catch block
+	framesIndex int // frames of the try block
+	sp          int // stack pointer when the try block started
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobalsStore(bytecode, make([]object.Object, GlobalsSize))
+}
+
+// NewWithGlobalsStore keeps globals between runs, e.g. in the REPL
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	module := &object.Module{Constants: bytecode.Constants, Globals: globals, GlobalNames: bytecode.GlobalNames}
+	bytecode.Main.Module = module
+	for _, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			fn.Module = module
+		}
+	}
+
+	mainClosure := &object.Closure{Fn: bytecode.Main}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	vm := &VM{
+		module:       module,
+		builtins:     evaluator.NewBuiltins(),
+		ctx:          object.NewExecContext(),
+		stack:        make([]object.Object, StackSize),
+		sp:           bytecode.Main.NumLocals,
+		frames:       frames,
+		framesIndex:  1,
+		openUpvalues: map[int]*object.Upvalue{},
+		modules:      evaluator.NewModules(),
+	}
+	vm.apply = vm.callback
+	return vm
+}
+
+// SetBuiltins replaces the builtins available to the program
+func (vm *VM) SetBuiltins(builtins evaluator.Builtins) {
+	vm.builtins = builtins
+}
+
+// SetContext replaces the context passed to builtins, e.g. to capture their output
+func (vm *VM) SetContext(ctx *object.ExecContext) {
+	vm.ctx = ctx
+}
+
+// SetModules replaces the modules available to imports, e.g. to share them or change the search path
+func (vm *VM) SetModules(modules *evaluator.Modules) {
+	vm.modules = modules
+}
+
+// SetFile sets the file of the program, imports are resolved relative to it
+func (vm *VM) SetFile(path string) {
+	vm.module.File = path
+}
+
+// SetLimits bounds the run, steps are executed instructions
+func (vm *VM) SetLimits(limits evaluator.Limits) {
+	vm.limits = limits
+}
+
+// RunContext is Run stopping when ctx is done
+func (vm *VM) RunContext(ctx context.Context) object.Object {
+	vm.done = ctx.Done()
+	defer func() { vm.done = nil }()
+
+	return vm.Run()
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, f)
+	} else {
+		vm.frames[vm.framesIndex] = f
+	}
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+// Run executes the program and returns the value of its last expression
+// statement, nil when there is none, or an *object.Error
+func (vm *VM) Run() object.Object {
+	return vm.execute()
+}
+
+// execute runs instructions until the function at vm.base returns,
+// errors caught by a try block continue in its catch block
+func (vm *VM) execute() object.Object {
+	for {
+		result := vm.run()
+		if err, ok := result.(*object.Error); !ok || !vm.catch(err) {
+			return result
+		}
+	}
+}
+
+// catch unwinds to the innermost try block and pushes err for its catch block,
+// it returns false when no try block handles err
+func (vm *VM) catch(err *object.Error) bool {
+	if len(vm.handlers) == 0 || !err.Catchable() {
+		return false
+	}
+	h := vm.handlers[len(vm.handlers)-1]
+	if h.framesIndex <= vm.base {
+		// the try block is outside of the function called back by a builtin
+		return false
+	}
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+	for vm.framesIndex > h.framesIndex {
+		frame := vm.popFrame()
+		vm.closeUpvalues(frame.basePointer)
+	}
+	vm.sp = h.sp
+	vm.stack[vm.sp] = err
+	vm.sp++
+	vm.currentFrame().ip = h.catch - 1
+	return true
+}
+
+func (vm *VM) run() object.Object {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for {
+		vm.currentFrame().ip++
+
+		vm.steps++
+		if vm.limits.MaxSteps > 0 && vm.steps > vm.limits.MaxSteps {
+			return vm.fail(object.LIMIT_ERROR, evaluator.LimitExceeded)
+		}
+		if vm.done != nil && vm.steps%1024 == 0 {
+			select {
+			case <-vm.done:
+				return vm.fail(object.LIMIT_ERROR, evaluator.LimitExceeded)
+			default:
+			}
+		}
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.push(vm.currentFrame().cl.Fn.Module.Constants[constIndex]); err != nil {
+				return err
+			}
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterThanEqual,
+			code.OpLessThan, code.OpLessThanEqual:
+			if err := vm.executeBinaryOperation(op); err != nil {
+				return err
+			}
+
+		case code.OpTrue:
+			if err := vm.push(TRUE); err != nil {
+				return err
+			}
+
+		case code.OpFalse:
+			if err := vm.push(FALSE); err != nil {
+				return err
+			}
+
+		case code.OpNull:
+			if err := vm.push(NULL); err != nil {
+				return err
+			}
+
+		case code.OpBang, code.OpMinus:
+			operator := "!"
+			if op == code.OpMinus {
+				operator = "-"
+			}
+			if err := vm.allocate(1); err != nil {
+				return err
+			}
+			result := evaluator.PrefixOperation(operator, vm.pop())
+			if err := vm.pushResult(result); err != nil {
+				return err
+			}
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if !evaluator.IsTruthy(vm.pop()) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpJumpNotTruthyOrPop, code.OpJumpTruthyOrPop:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if evaluator.IsTruthy(vm.stack[vm.sp-1]) == (op == code.OpJumpTruthyOrPop) {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpIter:
+			if err := vm.pushResult(evaluator.IterOperation(vm.pop())); err != nil {
+				return err
+			}
+
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			numVars := int(code.ReadUint8(ins[ip+3:]))
+			vm.currentFrame().ip += 3
+
+			it := vm.stack[vm.sp-1].(*object.Iterator)
+			key, value, ok := it.Next()
+			if !ok {
+				vm.currentFrame().ip = pos - 1
+				continue
+			}
+			if numVars == 2 {
+				if err := vm.push(key); err != nil {
+					return err
+				}
+			} else if it.Keys {
+				value = key
+			}
+			if err := vm.push(value); err != nil {
+				return err
+			}
+
+		case code.OpSkipDefault:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			localIndex := int(code.ReadUint8(ins[ip+3:]))
+			vm.currentFrame().ip += 3
+
+			if vm.stack[vm.currentFrame().basePointer+localIndex] != nil {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			module := vm.currentFrame().cl.Fn.Module
+			val := module.Globals[globalIndex]
+			if val == nil {
+				// builtins can be shadowed, so they are looked up last
+				name := module.GlobalNames[globalIndex]
+				builtin, ok := vm.builtins[name]
+				if !ok {
+					return vm.fail(object.NAME_ERROR, "identifier not found: %s", name)
+				}
+				val = builtin
+			}
+			if err := vm.push(val); err != nil {
+				return err
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.currentFrame().cl.Fn.Module.Globals[globalIndex] = vm.pop()
+
+		case code.OpAssignGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			module := vm.currentFrame().cl.Fn.Module
+			if module.Globals[globalIndex] == nil {
+				return vm.fail(object.NAME_ERROR, "identifier not found: %s", module.GlobalNames[globalIndex])
+			}
+			module.Globals[globalIndex] = vm.stack[vm.sp-1]
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			val := vm.stack[frame.basePointer+int(localIndex)]
+			if val == nil {
+				return vm.fail(object.NAME_ERROR, "identifier not found: %s", frame.cl.Fn.LocalNames[localIndex])
+			}
+			if err := vm.push(val); err != nil {
+				return err
+			}
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			vm.stack[vm.currentFrame().basePointer+int(localIndex)] = vm.pop()
+
+		case code.OpAssignLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			slot := frame.basePointer + int(localIndex)
+			if vm.stack[slot] == nil {
+				return vm.fail(object.NAME_ERROR, "identifier not found: %s", frame.cl.Fn.LocalNames[localIndex])
+			}
+			vm.stack[slot] = vm.stack[vm.sp-1]
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			cl := vm.currentFrame().cl
+			val := *cl.Free[freeIndex].Location
+			if val == nil {
+				return vm.fail(object.NAME_ERROR, "identifier not found: %s", cl.Fn.FreeNames[freeIndex])
+			}
+			if err := vm.push(val); err != nil {
+				return err
+			}
+
+		case code.OpAssignFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			cl := vm.currentFrame().cl
+			upvalue := cl.Free[freeIndex]
+			if *upvalue.Location == nil {
+				return vm.fail(object.NAME_ERROR, "identifier not found: %s", cl.Fn.FreeNames[freeIndex])
+			}
+			*upvalue.Location = vm.stack[vm.sp-1]
+
+		case code.OpCompoundAssign:
+			operator := code.AssignOperators[code.ReadUint8(ins[ip+1:])]
+			vm.currentFrame().ip += 1
+
+			cur := vm.pop()
+			val := vm.pop()
+			result := evaluator.CompoundAssignOperation(operator, cur, val)
+			if err := vm.pushResult(result); err != nil {
+				return err
+			}
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if err := vm.allocate(1 + numElements); err != nil {
+				return err
+			}
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(&object.Array{Elements: elements}); err != nil {
+				return err
+			}
+
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if err := vm.allocate(1); err != nil {
+				return err
+			}
+			str := evaluator.InterpolateOperation(vm.stack[vm.sp-numParts : vm.sp])
+			vm.sp = vm.sp - numParts
+
+			if err := vm.push(str); err != nil {
+				return err
+			}
+
+		case code.OpConcat:
+			numArrays := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			elements := []object.Object{}
+			for _, o := range vm.stack[vm.sp-numArrays : vm.sp] {
+				arr, ok := o.(*object.Array)
+				if !ok {
+					return vm.fail(object.TYPE_ERROR, "spread operator not supported: %s", o.Type())
+				}
+				elements = append(elements, arr.Elements...)
+			}
+			vm.sp = vm.sp - numArrays
+
+			if err := vm.allocate(1 + len(elements)); err != nil {
+				return err
+			}
+			if err := vm.push(&object.Array{Elements: elements}); err != nil {
+				return err
+			}
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if err := vm.allocate(1 + numElements/2); err != nil {
+				return err
+			}
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+
+			if err := vm.push(hash); err != nil {
+				return err
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.pushResult(evaluator.IndexOperation(left, index)); err != nil {
+				return err
+			}
+
+		case code.OpSlice:
+			step := vm.pop()
+			end := vm.pop()
+			start := vm.pop()
+			left := vm.pop()
+
+			if err := vm.allocate(1); err != nil {
+				return err
+			}
+			if err := vm.pushResult(evaluator.SliceOperation(left, start, end, step)); err != nil {
+				return err
+			}
+
+		case code.OpSetSlice:
+			operator := code.AssignOperators[code.ReadUint8(ins[ip+1:])]
+			vm.currentFrame().ip += 1
+
+			val := vm.pop()
+			step := vm.pop()
+			end := vm.pop()
+			start := vm.pop()
+			left := vm.pop()
+
+			result := evaluator.SliceAssignOperation(left, start, end, step, operator, val)
+			if err := vm.pushResult(result); err != nil {
+				return err
+			}
+
+		case code.OpSetIndex:
+			operator := code.AssignOperators[code.ReadUint8(ins[ip+1:])]
+			nameIndex := code.ReadUint16(ins[ip+2:])
+			vm.currentFrame().ip += 3
+
+			val := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			name := vm.currentFrame().cl.Fn.Module.Constants[nameIndex].(*object.String).Value
+			result := evaluator.IndexAssignOperation(name, left, index, operator, val)
+			if err := vm.pushResult(result); err != nil {
+				return err
+			}
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.executeCall(int(numArgs)); err != nil {
+				return err
+			}
+
+		case code.OpCallSpread:
+			args := vm.pop().(*object.Array)
+			for _, arg := range args.Elements {
+				if err := vm.push(arg); err != nil {
+					return err
+				}
+			}
+
+			if err := vm.executeCall(len(args.Elements)); err != nil {
+				return err
+			}
+
+		case code.OpReturnValue, code.OpReturn:
+			var returnValue object.Object
+			if op == code.OpReturnValue {
+				returnValue = vm.pop()
+			}
+
+			frame := vm.popFrame()
+			vm.closeUpvalues(frame.basePointer)
+			if vm.framesIndex == 0 {
+				return returnValue
+			}
+
+			if returnValue == nil {
+				returnValue = NULL
+			}
+			vm.sp = frame.basePointer - 1
+			if vm.framesIndex == vm.base {
+				return returnValue
+			}
+			if err := vm.push(returnValue); err != nil {
+				return err
+			}
+
+		case code.OpTry:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			vm.handlers = append(vm.handlers, handler{catch: pos, framesIndex: vm.framesIndex, sp: vm.sp})
+
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		case code.OpThrow:
+			// an error is thrown again as it is, after a finally block
+			if err, ok := vm.stack[vm.sp-1].(*object.Error); ok {
+				vm.pop()
+				return err
+			}
+			return vm.raise(evaluator.ThrowOperation(vm.pop()))
+
+		case code.OpCatch:
+			err := vm.pop().(*object.Error)
+			if err := vm.push(evaluator.CatchOperation(err)); err != nil {
+				return err
+			}
+
+		case code.OpImport:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			name := vm.currentFrame().cl.Fn.Module.Constants[nameIndex].(*object.String).Value
+			if err := vm.pushResult(vm.importModule(name)); err != nil {
+				return err
+			}
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := int(code.ReadUint8(ins[ip+3:]))
+			vm.currentFrame().ip += 3 + numFree*2
+
+			if err := vm.allocate(1); err != nil {
+				return err
+			}
+			captures := ins[ip+4 : ip+4+numFree*2]
+			if err := vm.pushClosure(int(constIndex), captures); err != nil {
+				return err
+			}
+
+		default:
+			def, _ := code.Lookup(byte(op))
+			return vm.fail(object.GENERIC_ERROR, "unsupported opcode %s", def.Name)
+		}
+	}
+}
+
+// importModule runs a module with its own globals the first time it is imported,
+// sharing the builtins and the limits of the importing program
+func (vm *VM) importModule(name string) object.Object {
+	// imports are resolved from the file of the running function, e.g. a module
+	importer := vm.currentFrame().cl.Fn.Module.File
+	return vm.modules.Import(name, importer, func(path string, program *ast.Program) object.Object {
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			return object.NewError(object.IMPORT_ERROR, "cannot compile module %s: %s", name, err)
+		}
+		bytecode := comp.Bytecode()
+
+		machine := New(bytecode)
+		machine.builtins, machine.ctx, machine.modules, machine.module.File = vm.builtins, vm.ctx, vm.modules, path
+		machine.limits, machine.done = vm.limits, vm.done
+		machine.steps, machine.allocations = vm.steps, vm.allocations
+		result := machine.Run()
+		vm.steps, vm.allocations = machine.steps, machine.allocations
+
+		if err, ok := result.(*object.Error); ok {
+			return vm.moduleError(err)
+		}
+
+		names := []string{}
+		values := map[string]object.Object{}
+		for name, index := range bytecode.Exports {
+			names = append(names, name)
+			values[name] = machine.module.Globals[index]
+		}
+		// globals are numbered in the order they are declared
+		sort.Slice(names, func(i, j int) bool {
+			return bytecode.Exports[names[i]] < bytecode.Exports[names[j]]
+		})
+		return evaluator.Namespace(names, values, result)
+	})
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) *object.Error {
+	if err := vm.allocate(1); err != nil {
+		return err
+	}
+
+	right := vm.pop()
+	left := vm.pop()
+
+	// fast path, integers are the most common operands
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			if result, ok := integerOperation(op, l.Value, r.Value); ok {
+				return vm.push(result)
+			}
+		}
+	}
+
+	return vm.pushResult(evaluator.InfixOperation(binaryOperators[op], left, right))
+}
+
+var binaryOperators = map[code.Opcode]string{
+	code.OpAdd:              "+",
+	code.OpSub:              "-",
+	code.OpMul:              "*",
+	code.OpDiv:              "/",
+	code.OpMod:              "%",
+	code.OpEqual:            "==",
+	code.OpNotEqual:         "!=",
+	code.OpGreaterThan:      ">",
+	code.OpGreaterThanEqual: ">=",
+	code.OpLessThan:         "<",
+	code.OpLessThanEqual:    "<=",
+}
+
+func integerOperation(op code.Opcode, left, right int64) (object.Object, bool) {
+	switch op {
+	// on overflow the evaluator promotes the result to a big integer
+	case code.OpAdd:
+		result := left + right
+		return &object.Integer{Value: result}, (result > left) == (right > 0)
+	case code.OpSub:
+		result := left - right
+		return &object.Integer{Value: result}, (result < left) == (right > 0)
+	case code.OpMul:
+		result := left * right
+		return &object.Integer{Value: result}, left == 0 || (result/left == right && !(left == -1 && right == math.MinInt64))
+	case code.OpEqual:
+		return evaluator.NativeBoolToBooleanObject(left == right), true
+	case code.OpNotEqual:
+		return evaluator.NativeBoolToBooleanObject(left != right), true
+	case code.OpGreaterThan:
+		return evaluator.NativeBoolToBooleanObject(left > right), true
+	case code.OpGreaterThanEqual:
+		return evaluator.NativeBoolToBooleanObject(left >= right), true
+	case code.OpLessThan:
+		return evaluator.NativeBoolToBooleanObject(left < right), true
+	case code.OpLessThanEqual:
+		return evaluator.NativeBoolToBooleanObject(left <= right), true
+	}
+	return nil, false
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, *object.Error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		frozen, hashKey, ok := object.HashKeyOf(key)
+		if !ok {
+			return nil, vm.fail(object.TYPE_ERROR, "unusable as hash key: %s", key.Type())
+		}
+		hash.Set(hashKey, object.HashPair{Key: frozen, Value: value})
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) executeCall(numArgs int) *object.Error {
+	// a call allocates like in the evaluator, e.g. the result of a builtin
+	if err := vm.allocate(1); err != nil {
+		return err
+	}
+
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return vm.fail(object.TYPE_ERROR, "not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) *object.Error {
+	fn := cl.Fn
+	required, total := fn.NumParameters-fn.NumDefaults, fn.NumParameters
+	if fn.Rest {
+		total = -1
+	}
+	if numArgs < required || (total >= 0 && numArgs > total) {
+		return vm.fail(object.TYPE_ERROR, "%s", evaluator.WrongNumberOfArguments(required, total, numArgs))
+	}
+
+	basePointer := vm.sp - numArgs
+	// the main frame is not a call, the evaluator does not count it either
+	if vm.framesIndex > vm.limits.Depth() {
+		return vm.fail(object.LIMIT_ERROR, evaluator.LimitExceeded)
+	}
+	vm.growStack(basePointer + fn.NumLocals)
+
+	// the rest of the locals start undefined, missing arguments get their default value
+	for i := numArgs; i < fn.NumLocals; i++ {
+		vm.stack[basePointer+i] = nil
+	}
+
+	// the extra arguments are moved to an array in the local after the parameters
+	if fn.Rest {
+		rest := []object.Object{}
+		if numArgs > fn.NumParameters {
+			rest = make([]object.Object, numArgs-fn.NumParameters)
+			copy(rest, vm.stack[basePointer+fn.NumParameters:basePointer+numArgs])
+			for i := fn.NumParameters + 1; i < numArgs; i++ {
+				vm.stack[basePointer+i] = nil
+			}
+		}
+		vm.stack[basePointer+fn.NumParameters] = &object.Array{Elements: rest}
+	}
+
+	vm.pushFrame(NewFrame(cl, basePointer))
+	vm.sp = basePointer + fn.NumLocals
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) *object.Error {
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+
+	apply := vm.ctx.Apply
+	vm.ctx.Apply = vm.apply
+	result := builtin.Call(vm.ctx, args...)
+	vm.ctx.Apply = apply
+	vm.sp = vm.sp - numArgs - 1
+
+	if result == nil {
+		result = NULL
+	}
+	return vm.pushResult(result)
+}
+
+// callback calls fn from a builtin, running a closure until it returns
+func (vm *VM) callback(fn object.Object, args []object.Object) object.Object {
+	cl, ok := fn.(*object.Closure)
+	if !ok {
+		if builtin, ok := fn.(*object.Builtin); ok {
+			return builtin.Call(vm.ctx, args...)
+		}
+		return object.NewError(object.TYPE_ERROR, "not a function: %s", fn.Type())
+	}
+
+	sp, base := vm.sp, vm.base
+	defer func() { vm.base = base }()
+
+	vm.base = vm.framesIndex
+	if err := vm.push(cl); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if err := vm.push(arg); err != nil {
+			return err
+		}
+	}
+	if err := vm.callClosure(cl, len(args)); err != nil {
+		vm.sp = sp
+		return err
+	}
+
+	result := vm.execute()
+	if err, ok := result.(*object.Error); ok {
+		// the error leaves the builtin, so do the frames of the call
+		for vm.framesIndex > vm.base {
+			frame := vm.popFrame()
+			vm.closeUpvalues(frame.basePointer)
+		}
+		vm.sp = sp
+		return err
+	}
+	return result
+}
+
+func (vm *VM) pushClosure(constIndex int, captures []byte) *object.Error {
+	constant := vm.currentFrame().cl.Fn.Module.Constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return vm.fail(object.TYPE_ERROR, "not a function: %+v", constant)
+	}
+
+	frame := vm.currentFrame()
+	free := make([]*object.Upvalue, len(captures)/2)
+	for i := range free {
+		isLocal, index := captures[i*2], int(captures[i*2+1])
+		if isLocal == 1 {
+			free[i] = vm.captureUpvalue(frame.basePointer + index)
+		} else {
+			free[i] = frame.cl.Free[index]
+		}
+	}
+
+	return vm.push(&object.Closure{Fn: function, Free: free})
+}
+
+// captureUpvalue shares the upvalue between closures capturing the same variable
+func (vm *VM) captureUpvalue(slot int) *object.Upvalue {
+	if upvalue, ok := vm.openUpvalues[slot]; ok {
+		return upvalue
+	}
+	upvalue := &object.Upvalue{Location: &vm.stack[slot]}
+	vm.openUpvalues[slot] = upvalue
+	return upvalue
+}
+
+// closeUpvalues moves variables at or above the stack slot out of the stack
+func (vm *VM) closeUpvalues(slot int) {
+	if len(vm.openUpvalues) == 0 {
+		return
+	}
+	for i, upvalue := range vm.openUpvalues {
+		if i >= slot {
+			upvalue.Close()
+			delete(vm.openUpvalues, i)
+		}
+	}
+}
+
+func (vm *VM) allocate(n int) *object.Error {
+	if vm.limits.MaxAllocations == 0 {
+		return nil
+	}
+	vm.allocations += n
+	if vm.allocations > vm.limits.MaxAllocations {
+		return vm.fail(object.LIMIT_ERROR, evaluator.LimitExceeded)
+	}
+	return nil
+}
+
+func (vm *VM) push(o object.Object) *object.Error {
+	vm.growStack(vm.sp + 1)
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+// growStack makes room for size slots, the stack is only bounded by the depth limit
+func (vm *VM) growStack(size int) {
+	if size <= len(vm.stack) {
+		return
+	}
+	stack := make([]object.Object, max(size, 2*len(vm.stack)))
+	copy(stack, vm.stack)
+	vm.stack = stack
+
+	// open upvalues point into the old stack
+	for slot, upvalue := range vm.openUpvalues {
+		upvalue.Location = &vm.stack[slot]
+	}
+}
+
+// pushResult pushes the result of an operation, failing when it is an error
+func (vm *VM) pushResult(o object.Object) *object.Error {
+	if err, ok := o.(*object.Error); ok {
+		return vm.raise(err)
+	}
+	return vm.push(o)
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// fail creates an error of the kind at the current instruction
+func (vm *VM) fail(kind object.ErrorKind, format string, a ...any) *object.Error {
+	return vm.raise(object.NewError(kind, format, a...))
+}
+
+// raise positions err at the current instruction, with the call site of
+// every active function as stack trace
+func (vm *VM) raise(err *object.Error) *object.Error {
+	// errors of imported modules are already positioned in the module
+	if err.Position.IsValid() {
+		return err
+	}
+
+	frame := vm.currentFrame()
+	err.File, err.Position = frame.cl.Fn.Module.File, frame.cl.Fn.PositionAt(frame.ip)
+	err.Stack = vm.callStack()
+	return err
+}
+
+// moduleError puts the active calls and the import running the module
+// under the stack of an error raised by an imported module
+func (vm *VM) moduleError(err *object.Error) *object.Error {
+	frame := vm.currentFrame()
+	stack := append(vm.callStack(), object.StackFrame{
+		Function: object.ModuleFunction,
+		File:     frame.cl.Fn.Module.File,
+		Position: frame.cl.Fn.PositionAt(frame.ip),
+	})
+	err.Stack = append(stack, err.Stack...)
+	return err
+}
+
+// callStack is the call site of every active function, outermost first
+func (vm *VM) callStack() []object.StackFrame {
+	var stack []object.StackFrame
+	for i := 1; i < vm.framesIndex; i++ {
+		caller := vm.frames[i-1]
+		stack = append(stack, object.StackFrame{
+			Function: vm.frames[i].cl.Fn.Name,
+			File:     caller.cl.Fn.Module.File,
+			Position: caller.cl.Fn.PositionAt(caller.ip),
+		})
+	}
+	return stack
+}
@@ -0,0 +1,132 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/labasubagia/interpreter/compiler"
+	"github.com/labasubagia/interpreter/evaluator"
+	"github.com/labasubagia/interpreter/internal/enginetest"
+	"github.com/labasubagia/interpreter/lexer"
+	"github.com/labasubagia/interpreter/object"
+	"github.com/labasubagia/interpreter/parser"
+)
+
+var engine = enginetest.Engine{
+	Run: testRun,
+	RunFile: func(input, file string, modules *evaluator.Modules) object.Object {
+		machine, err := compile(input)
+		if err != nil {
+			return err
+		}
+		machine.SetModules(modules)
+		machine.SetFile(file)
+		return machine.Run()
+	},
+	RunContext: func(ctx context.Context, input string, limits evaluator.Limits) object.Object {
+		machine, err := compile(input)
+		if err != nil {
+			return err
+		}
+		machine.SetLimits(limits)
+		return machine.RunContext(ctx)
+	},
+}
+
+func TestEngine(t *testing.T) {
+	enginetest.Run(t, engine)
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "fn(x) { x + 2 }"
+
+	obj := testRun(input)
+	cl, ok := obj.(*object.Closure)
+	if !ok {
+		t.Fatalf("object is not Closure. got=%T (%+v)", obj, obj)
+	}
+	fn := cl.Fn.Literal
+
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("function has the wrong parameters. Parameters=%+v", fn.Parameters)
+	}
+
+	if fn.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
+	}
+
+	expectedBody := "(x + 2)"
+	if fn.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.String())
+	}
+}
+
+func TestBuiltinContext(t *testing.T) {
+	input := `
+		let name = input("name? ");
+		puts("hello", name);
+		puts(input());
+	`
+	var out bytes.Buffer
+	ctx := &object.ExecContext{Stdout: &out, Stdin: strings.NewReader("bob\n")}
+
+	machine := testVM(t, input)
+	machine.SetContext(ctx)
+	machine.Run()
+
+	expected := "name? hello bob\nnull\n"
+	if out.String() != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, out.String())
+	}
+}
+
+func testRun(input string) object.Object {
+	machine, err := compile(input)
+	if err != nil {
+		return err
+	}
+	return machine.Run()
+}
+
+// compile returns a vm running input, or the compile error
+func compile(input string) (*VM, *object.Error) {
+	program, parseErr := enginetest.Parse(input)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		compileErr := err.(*compiler.CompileError)
+		return nil, &object.Error{Message: compileErr.Message, Position: compileErr.Position}
+	}
+	return New(comp.Bytecode()), nil
+}
+
+func BenchmarkFibonacci(b *testing.B) {
+	input := `
+		let fib = fn(n) {
+			if (n < 2) {
+				return n;
+			}
+			fib(n - 1) + fib(n - 2);
+		};
+		fib(20);
+	`
+	for i := 0; i < b.N; i++ {
+		testRun(input)
+	}
+}
+
+func testVM(t *testing.T, input string) *VM {
+	t.Helper()
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return New(comp.Bytecode())
+}